@@ -0,0 +1,148 @@
+package sprites
+
+import (
+	"encoding/json"
+	"image"
+	"image/gif"
+)
+
+// An Animation is a decoded pic animation script.
+//
+// Steps lists the frame commands in the order they appear in the script.
+// Each loop plays Steps[Start:End] Count more times after reaching End,
+// so Steps[Start:End] is shown Count+1 times in all. Loops don't overlap and
+// are sorted by Start.
+type Animation struct {
+	Frames []*image.Paletted // frame 0 is the original pic
+	Steps  []AnimStep
+	Loops  []AnimLoop
+}
+
+// An AnimStep shows a frame for a number of ticks (1/60 s).
+type AnimStep struct {
+	Frame    int `json:"frame"`
+	Duration int `json:"duration"`
+}
+
+// An AnimLoop is a setrepeat/dorepeat pair.
+type AnimLoop struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+	Count int `json:"count"`
+}
+
+// The animation commands. Anything lower is a frame command.
+const (
+	animDoRepeat  = 0xFD
+	animSetRepeat = 0xFE
+	animEnd       = 0xFF
+)
+
+// Animate decodes an animation script.
+//
+// The game keeps a single repeat counter, which dorepeat counts down to
+// zero. A dorepeat which doesn't find the counter set falls through, so a
+// loop whose body contains another dorepeat never repeats. Scripts which
+// jump forward or back over a setrepeat can't be represented and are
+// rejected as malformed; the latter would loop forever anyway.
+func animate(animdata []byte, frames []*image.Paletted) (*Animation, error) {
+	a := &Animation{Frames: frames}
+	var loop int
+	// stepAt[i] is the number of frame commands before command i.
+	var stepAt []int
+	// lastSet is the index of the last setrepeat command, or -1.
+	lastSet := -1
+	for pc := 0; ; pc += 2 {
+		if pc+1 >= len(animdata) {
+			if pc < len(animdata) && animdata[pc] == animEnd {
+				break
+			}
+			return nil, ErrMalformed
+		}
+		i := pc / 2
+		stepAt = append(stepAt, len(a.Steps))
+		switch animdata[pc] {
+		case animEnd:
+			return a, nil
+		case animSetRepeat:
+			loop = int(animdata[pc+1])
+			lastSet = i
+		case animDoRepeat:
+			if loop == 0 {
+				continue
+			}
+			target := int(animdata[pc+1])
+			if target > i || target <= lastSet {
+				return nil, ErrMalformed
+			}
+			if stepAt[target] < len(a.Steps) {
+				a.Loops = append(a.Loops, AnimLoop{stepAt[target], len(a.Steps), loop})
+			}
+			loop = 0
+		default:
+			if int(animdata[pc]) >= len(frames) {
+				return nil, ErrMalformed
+			}
+			a.Steps = append(a.Steps, AnimStep{int(animdata[pc]), int(animdata[pc+1])})
+		}
+	}
+	return a, nil
+}
+
+// Expand returns the steps in the order they are played, with the loops
+// unrolled.
+func (a *Animation) Expand() []AnimStep {
+	var steps []AnimStep
+	pos := 0
+	for _, l := range a.Loops {
+		steps = append(steps, a.Steps[pos:l.End]...)
+		for k := 0; k < l.Count; k++ {
+			steps = append(steps, a.Steps[l.Start:l.End]...)
+		}
+		pos = l.End
+	}
+	return append(steps, a.Steps[pos:]...)
+}
+
+// Duration returns the length of the animation in ticks.
+func (a *Animation) Duration() int {
+	var clock int
+	for _, s := range a.Expand() {
+		clock += s.Duration
+	}
+	return clock
+}
+
+// GIF renders the animation as a GIF. Delays are rounded to centiseconds
+// without accumulating error. The original pic is shown at the end, as a
+// rest before the animation repeats.
+func (a *Animation) GIF() *gif.GIF {
+	var g gif.GIF
+	var clock int
+	for _, s := range a.Expand() {
+		g.Image = append(g.Image, a.Frames[s.Frame])
+		g.Delay = append(g.Delay, (clock+s.Duration)*100/60-clock*100/60)
+		clock += s.Duration
+	}
+	g.Image = append(g.Image, a.Frames[0])
+	g.Delay = append(g.Delay, clock*2*100/60-clock)
+	return &g
+}
+
+// MarshalJSON encodes the timing data of the animation. The frames
+// themselves are left out; only their number is recorded.
+func (a *Animation) MarshalJSON() ([]byte, error) {
+	steps := a.Steps
+	if steps == nil {
+		steps = []AnimStep{}
+	}
+	loops := a.Loops
+	if loops == nil {
+		loops = []AnimLoop{}
+	}
+	return json.Marshal(struct {
+		Frames int        `json:"frames"`
+		Steps  []AnimStep `json:"steps"`
+		Loops  []AnimLoop `json:"loops"`
+	}{len(a.Frames), steps, loops})
+}
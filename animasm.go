@@ -0,0 +1,167 @@
+// +build ignore
+
+// Animasm disassembles the animation data for a Pokémon from a Crystal ROM
+// into pokecrystal-style text files, or assembles those files and compares
+// them against the ROM.
+//
+//	go run animasm.go -n 25 -out pikachu crystal.gbc
+//	go run animasm.go -n 25 -asm pikachu crystal.gbc
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/magical/sprites"
+)
+
+var (
+	number int
+	form   string
+	outdir string
+	asmdir string
+)
+
+// The files that make up a pic's animation, as named in pokecrystal.
+const (
+	animFile    = "anim.asm"
+	extraFile   = "anim_idle.asm"
+	bitmaskFile = "bitmask.asm"
+	framesFile  = "frames.asm"
+)
+
+func main() {
+	flag.IntVar(&number, "n", 0, "number of pokemon")
+	flag.StringVar(&form, "form", "", "unown form")
+	flag.StringVar(&outdir, "out", ".", "output directory")
+	flag.StringVar(&asmdir, "asm", "", "assemble the files in this directory and compare them to the ROM")
+	flag.Parse()
+
+	err := run()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	f, err := os.Open(flag.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	rip, err := sprites.NewRipper(f)
+	if err != nil {
+		return err
+	}
+	if !rip.HasAnimations() {
+		return fmt.Errorf("%s: no animations", flag.Arg(0))
+	}
+
+	var d *sprites.AnimData
+	if form != "" {
+		number = 201
+		d, err = rip.UnownAnimData(form)
+	} else {
+		d, err = rip.PokemonAnimData(number)
+	}
+	if err != nil {
+		return err
+	}
+
+	if asmdir != "" {
+		m, err := rip.Pokemon(number)
+		if err != nil {
+			return err
+		}
+		w, h := m.Rect.Dx()/8, m.Rect.Dy()/8
+		return compare(d, asmdir, w, h)
+	}
+	return disassemble(d, outdir)
+}
+
+func disassemble(d *sprites.AnimData, dir string) error {
+	err := os.MkdirAll(dir, 0777)
+	if err != nil {
+		return err
+	}
+	for _, t := range []struct {
+		name string
+		fn   func(io.Writer) error
+	}{
+		{animFile, func(w io.Writer) error { return sprites.WriteAnimScript(w, d.Anim) }},
+		{extraFile, func(w io.Writer) error { return sprites.WriteAnimScript(w, d.Extra) }},
+		{bitmaskFile, func(w io.Writer) error { return sprites.WriteBitmasks(w, d.Bitmaps) }},
+		{framesFile, func(w io.Writer) error { return sprites.WriteFrames(w, d.Frames) }},
+	} {
+		f, err := os.Create(filepath.Join(dir, t.name))
+		if err != nil {
+			return err
+		}
+		err = t.fn(f)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func compare(d *sprites.AnimData, dir string, w, h int) error {
+	var asm sprites.AnimData
+	for _, t := range []struct {
+		name string
+		fn   func(io.Reader) error
+	}{
+		{animFile, func(r io.Reader) (err error) { asm.Anim, err = sprites.ParseAnimScript(r); return }},
+		{extraFile, func(r io.Reader) (err error) { asm.Extra, err = sprites.ParseAnimScript(r); return }},
+		{bitmaskFile, func(r io.Reader) (err error) { asm.Bitmaps, err = sprites.ParseBitmasks(r, w, h); return }},
+		{framesFile, func(r io.Reader) (err error) { asm.Frames, err = sprites.ParseFrames(r); return }},
+	} {
+		filename := filepath.Join(dir, t.name)
+		f, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+		err = t.fn(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %s", filename, err)
+		}
+	}
+
+	same := true
+	check := func(name string, got, want []byte) {
+		if !bytes.Equal(got, want) {
+			fmt.Printf("%s differs:\n\tasm: % x\n\trom: % x\n", name, got, want)
+			same = false
+		}
+	}
+	check(animFile, asm.Anim, d.Anim)
+	check(extraFile, asm.Extra, d.Extra)
+	if len(asm.Bitmaps) != len(d.Bitmaps) {
+		fmt.Printf("%s: %d bitmasks, rom has %d\n", bitmaskFile, len(asm.Bitmaps), len(d.Bitmaps))
+		same = false
+	}
+	for i := 0; i < len(asm.Bitmaps) && i < len(d.Bitmaps); i++ {
+		check(fmt.Sprintf("%s: bitmask %d", bitmaskFile, i), asm.Bitmaps[i], d.Bitmaps[i])
+	}
+	if len(asm.Frames) != len(d.Frames) {
+		fmt.Printf("%s: %d frames, rom has %d\n", framesFile, len(asm.Frames), len(d.Frames))
+		same = false
+	}
+	for i := 0; i < len(asm.Frames) && i < len(d.Frames); i++ {
+		check(fmt.Sprintf("%s: frame %d", framesFile, i+1), asm.Frames[i], d.Frames[i])
+	}
+	if same {
+		fmt.Println("matches ROM")
+	}
+	return nil
+}
@@ -0,0 +1,305 @@
+package sprites
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Text forms of the animation data, in the style of the pokecrystal
+// disassembly: anim.asm and anim_idle.asm for the scripts, bitmask.asm for
+// the bitmaps, and frames.asm for the frames.
+
+// WriteAnimScript disassembles an animation script.
+func WriteAnimScript(w io.Writer, script []byte) error {
+	bw := bufio.NewWriter(w)
+	for pc := 0; pc < len(script); pc += 2 {
+		if script[pc] == animEnd {
+			fmt.Fprintf(bw, "\tendanim\n")
+			break
+		}
+		if pc+1 >= len(script) {
+			return ErrMalformed
+		}
+		arg := script[pc+1]
+		switch script[pc] {
+		case animSetRepeat:
+			fmt.Fprintf(bw, "\tsetrepeat %d\n", arg)
+		case animDoRepeat:
+			fmt.Fprintf(bw, "\tdorepeat %d\n", arg)
+		default:
+			fmt.Fprintf(bw, "\tframe %d, %02d\n", script[pc], arg)
+		}
+	}
+	return bw.Flush()
+}
+
+// WriteBitmasks disassembles a list of bitmaps.
+func WriteBitmasks(w io.Writer, bitmaps [][]byte) error {
+	bw := bufio.NewWriter(w)
+	for i, b := range bitmaps {
+		fmt.Fprintf(bw, "; %d\n", i)
+		for _, x := range b {
+			fmt.Fprintf(bw, "\tdb %%%08b\n", x)
+		}
+	}
+	return bw.Flush()
+}
+
+// WriteFrames disassembles a list of frames.
+func WriteFrames(w io.Writer, frames [][]byte) error {
+	bw := bufio.NewWriter(w)
+	for i := range frames {
+		fmt.Fprintf(bw, "\tdw .frame%d\n", i+1)
+	}
+	for i, f := range frames {
+		fmt.Fprintf(bw, ".frame%d\n", i+1)
+		if len(f) == 0 {
+			continue
+		}
+		fmt.Fprintf(bw, "\tdb $%02x ; bitmask\n", f[0])
+		if len(f) > 1 {
+			fmt.Fprintf(bw, "\tdb ")
+			for j, x := range f[1:] {
+				if j > 0 {
+					fmt.Fprintf(bw, ", ")
+				}
+				fmt.Fprintf(bw, "$%02x", x)
+			}
+			fmt.Fprintf(bw, "\n")
+		}
+	}
+	return bw.Flush()
+}
+
+// An AsmError records a syntax error in an assembly file.
+type AsmError struct {
+	Line int
+	Text string
+	Err  string
+}
+
+func (e *AsmError) Error() string {
+	return fmt.Sprintf("line %d: %s: %q", e.Line, e.Err, e.Text)
+}
+
+// ParseAnimScript assembles an animation script.
+func ParseAnimScript(r io.Reader) ([]byte, error) {
+	var script []byte
+	err := scanAsm(r, func(op string, args []string) string {
+		var want int
+		var cmd byte
+		switch op {
+		case "frame":
+			want = 2
+		case "setrepeat":
+			want, cmd = 1, animSetRepeat
+		case "dorepeat":
+			want, cmd = 1, animDoRepeat
+		case "endanim":
+			want, cmd = 0, animEnd
+		default:
+			return "unknown command"
+		}
+		if len(args) != want {
+			return "wrong number of arguments"
+		}
+		var b []byte
+		for _, a := range args {
+			n, ok := parseByte(a)
+			if !ok {
+				return "bad number"
+			}
+			b = append(b, n)
+		}
+		if op == "frame" {
+			if b[0] >= animDoRepeat {
+				return "frame number out of range"
+			}
+			script = append(script, b...)
+		} else {
+			script = append(script, cmd)
+			script = append(script, b...)
+		}
+		return ""
+	})
+	return script, err
+}
+
+// ParseBitmasks assembles a list of bitmaps for a pic w by h tiles in size.
+func ParseBitmasks(r io.Reader, w, h int) ([][]byte, error) {
+	var data []byte
+	err := scanAsm(r, func(op string, args []string) string {
+		if op != "db" {
+			return "unknown command"
+		}
+		return appendBytes(&data, args)
+	})
+	if err != nil {
+		return nil, err
+	}
+	size := (w*h + 7) / 8
+	if size == 0 || len(data)%size != 0 {
+		return nil, fmt.Errorf("bitmasks are %d bytes, not a multiple of %d", len(data), size)
+	}
+	var bitmaps [][]byte
+	for i := 0; i < len(data); i += size {
+		bitmaps = append(bitmaps, data[i:i+size:i+size])
+	}
+	return bitmaps, nil
+}
+
+// ParseFrames assembles a list of frames. Frames are returned in the order
+// given by the dw table, or in the order they appear if there isn't one.
+func ParseFrames(r io.Reader) ([][]byte, error) {
+	var order []string
+	var labels []string
+	var frames = map[string][]byte{}
+	var cur string
+	err := scanAsm(r, func(op string, args []string) string {
+		switch {
+		case strings.HasPrefix(op, "."):
+			cur = strings.TrimSuffix(op, ":")
+			if _, ok := frames[cur]; ok {
+				return "duplicate label"
+			}
+			frames[cur] = []byte{}
+			labels = append(labels, cur)
+		case op == "dw":
+			order = append(order, args...)
+		case op == "db":
+			if cur == "" {
+				return "data outside of a frame"
+			}
+			b := frames[cur]
+			msg := appendBytes(&b, args)
+			frames[cur] = b
+			return msg
+		default:
+			return "unknown command"
+		}
+		return ""
+	})
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
+		order = labels
+	}
+	var list [][]byte
+	for _, name := range order {
+		f, ok := frames[name]
+		if !ok {
+			return nil, fmt.Errorf("undefined frame %s", name)
+		}
+		list = append(list, f)
+	}
+	return list, nil
+}
+
+// ScanAsm calls fn for each instruction or label in r. Fn returns a
+// description of the problem if the line is bad.
+func scanAsm(r io.Reader, fn func(op string, args []string) string) error {
+	s := bufio.NewScanner(r)
+	for lineno := 1; s.Scan(); lineno++ {
+		line := s.Text()
+		if i := strings.IndexByte(line, ';'); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		op, rest := line, ""
+		if i := strings.IndexAny(line, " \t"); i >= 0 {
+			op, rest = line[:i], strings.TrimSpace(line[i:])
+		}
+		var args []string
+		if rest != "" {
+			for _, a := range strings.Split(rest, ",") {
+				args = append(args, strings.TrimSpace(a))
+			}
+		}
+		if msg := fn(op, args); msg != "" {
+			return &AsmError{lineno, s.Text(), msg}
+		}
+	}
+	return s.Err()
+}
+
+func appendBytes(b *[]byte, args []string) string {
+	if len(args) == 0 {
+		return "wrong number of arguments"
+	}
+	for _, a := range args {
+		n, ok := parseByte(a)
+		if !ok {
+			return "bad number"
+		}
+		*b = append(*b, n)
+	}
+	return ""
+}
+
+// ParseByte parses a decimal, $hex, or %binary number.
+func parseByte(s string) (byte, bool) {
+	base := 10
+	switch {
+	case strings.HasPrefix(s, "$"):
+		s, base = s[1:], 16
+	case strings.HasPrefix(s, "%"):
+		s, base = s[1:], 2
+	}
+	n, err := strconv.ParseUint(s, base, 8)
+	return byte(n), err == nil
+}
+
+// WritePalette writes the two middle colors of a pic palette as RGB lines,
+// as in normal.pal and shiny.pal.
+func WritePalette(w io.Writer, pal color.Palette) error {
+	bw := bufio.NewWriter(w)
+	for _, c := range pal[1:3] {
+		r, g, b, _ := c.RGBA()
+		fmt.Fprintf(bw, "\tRGB %02d, %02d, %02d\n", to5(r), to5(g), to5(b))
+	}
+	return bw.Flush()
+}
+
+// To5 converts a 16-bit color component to 5 bits.
+func to5(x uint32) uint32 {
+	return (x*31 + 0x7FFF) / 0xFFFF
+}
+
+// ParsePalette reads a palette written by WritePalette. White and black are
+// added at either end.
+func ParsePalette(r io.Reader) (color.Palette, error) {
+	pal := color.Palette{color.White}
+	err := scanAsm(r, func(op string, args []string) string {
+		if op != "RGB" {
+			return "unknown command"
+		}
+		if len(args) != 3 {
+			return "wrong number of arguments"
+		}
+		var rgb [3]byte
+		for i, a := range args {
+			n, ok := parseByte(a)
+			if !ok || n > 31 {
+				return "bad color"
+			}
+			rgb[i] = n
+		}
+		pal = append(pal, RGB15(uint16(rgb[0])|uint16(rgb[1])<<5|uint16(rgb[2])<<10))
+		return ""
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(pal) != 3 {
+		return nil, fmt.Errorf("palette has %d colors, want 2", len(pal)-1)
+	}
+	return append(pal, color.Black), nil
+}
@@ -0,0 +1,63 @@
+package sprites
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAnimScriptAsm(t *testing.T) {
+	script := []byte{0x01, 0x0F, 0xFE, 0x02, 0x02, 0x08, 0x00, 0x05, 0xFD, 0x01, 0xFF}
+	var buf bytes.Buffer
+	if err := WriteAnimScript(&buf, script); err != nil {
+		t.Fatal(err)
+	}
+	want := "\tframe 1, 15\n\tsetrepeat 2\n\tframe 2, 08\n\tframe 0, 05\n\tdorepeat 1\n\tendanim\n"
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+	got, err := ParseAnimScript(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, script) {
+		t.Errorf("got %x, want %x", got, script)
+	}
+}
+
+func TestFramesAsm(t *testing.T) {
+	bitmaps := [][]byte{{0x01, 0x00, 0x00, 0x01}, {0xC0, 0x01, 0x00, 0x00}}
+	frames := [][]byte{{0x00, 0x19, 0x1A}, {0x01, 0x19, 0x1A, 0x19}}
+
+	var buf bytes.Buffer
+	if err := WriteBitmasks(&buf, bitmaps); err != nil {
+		t.Fatal(err)
+	}
+	gotBitmaps, err := ParseBitmasks(&buf, 5, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotBitmaps, bitmaps) {
+		t.Errorf("bitmaps: got %x, want %x", gotBitmaps, bitmaps)
+	}
+
+	buf.Reset()
+	if err := WriteFrames(&buf, frames); err != nil {
+		t.Fatal(err)
+	}
+	gotFrames, err := ParseFrames(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotFrames, frames) {
+		t.Errorf("frames: got %x, want %x", gotFrames, frames)
+	}
+}
+
+func TestAsmError(t *testing.T) {
+	_, err := ParseAnimScript(strings.NewReader("\tframe 1, 15\n\tjump 3\n"))
+	if e, ok := err.(*AsmError); !ok || e.Line != 2 {
+		t.Errorf("got %v, want an error on line 2", err)
+	}
+}
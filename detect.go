@@ -0,0 +1,447 @@
+package sprites
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// Signature scanning for ROMs that aren't in romtab, or whose offsets don't
+// match the ones in romtab (e.g. other languages). Only the base stats,
+// palettes, and pic pointer tables can be found this way; the animation
+// tables are left unset.
+//
+// Tables which romtab doesn't list, such as the menu icons and footprints,
+// are found through the code that reads them; see codePattern.
+
+var (
+	// Bulbasaur's number and base stats
+	bulbasaurStats = []byte{1, 45, 49, 49, 45, 65, 65}
+
+	// The palettes for the ??? entry at the start of the palette table
+	unknownPalettes = []byte{0x5E, 0x2F, 0x17, 0x02, 0x5E, 0x2F, 0x17, 0x02}
+)
+
+var titleVersions = map[string]string{
+	"POKEMON_GLD": "gold",
+	"POKEMON_SLV": "silver",
+	"PM_CRYSTAL":  "crystal",
+}
+
+const (
+	statsSize       = 32
+	spriteSizeIndex = 17 // offset of SpriteSize in the base stats
+
+	paletteTableSize = 256 * 2 * 4
+
+	bankSize = 0x4000
+)
+
+// CheckInfo reports whether the stats offset in info points at Bulbasaur.
+func checkInfo(r io.ReaderAt, info RomInfo) bool {
+	var b [7]byte
+	_, err := r.ReadAt(b[:], info.StatsOffset)
+	return err == nil && bytes.Equal(b[:], bulbasaurStats)
+}
+
+// ScanRom locates the tables in a ROM by searching for known data.
+func scanRom(rom []byte, title string) (info RomInfo, err error) {
+	version, ok := titleVersions[title]
+	if !ok {
+		return info, errors.New("Couldn't recognize ROM")
+	}
+	info.Title = title
+	info.Version = version
+	crystal := title == "PM_CRYSTAL"
+
+	info.StatsOffset = findStats(rom)
+	if info.StatsOffset < 0 {
+		return info, errors.New("Couldn't find Bulbasaur's stats")
+	}
+
+	info.PaletteOffset = findPalettes(rom)
+	if info.PaletteOffset < 0 {
+		return info, errors.New("Couldn't find palettes")
+	}
+	// The trainer palettes follow the Pokémon palettes. Trainer class 0
+	// doesn't exist, so skip it.
+	info.TrainerPaletteOffset = info.PaletteOffset + paletteTableSize + 4
+
+	size := func(number int) (w, h int) {
+		b := rom[info.StatsOffset+statsSize*int64(number-1)+spriteSizeIndex]
+		return int(b >> 4 & 0xF), int(b & 0xF)
+	}
+
+	// The pointer tables all start at the beginning of a bank.
+	for bank := int64(1); bank*bankSize < int64(len(rom)); bank++ {
+		off := bank * bankSize
+		switch {
+		case info.SpriteOffset == 0:
+			w, h := size(1)
+			w2, h2 := size(MaxPokemon)
+			if checkPicTable(rom, off, MaxPokemon*2, crystal, []picCheck{
+				{0, w, h},
+				{1, 6, 6},
+				{MaxPokemon*2 - 2, w2, h2},
+			}) {
+				info.SpriteOffset = off
+			}
+		case info.UnownSpriteOffset == 0:
+			w, h := size(201)
+			if checkPicTable(rom, off, len(UnownForms)*2, crystal, []picCheck{
+				{0, w, h},
+				{1, 6, 6},
+				{len(UnownForms)*2 - 2, w, h},
+			}) {
+				info.UnownSpriteOffset = off
+			}
+		case info.TrainerOffset == 0:
+			if checkPicTable(rom, off, MaxTrainer, crystal, []picCheck{
+				{0, 7, 7},
+				{MaxTrainer - 1, 7, 7},
+			}) {
+				info.TrainerOffset = off
+			}
+		}
+	}
+	if info.SpriteOffset == 0 || info.UnownSpriteOffset == 0 || info.TrainerOffset == 0 {
+		return info, errors.New("Couldn't find sprite pointers")
+	}
+	return info, nil
+}
+
+func findStats(rom []byte) int64 {
+	for i := 0; ; {
+		pos := bytes.Index(rom[i:], bulbasaurStats)
+		if pos < 0 {
+			return -1
+		}
+		pos += i
+		i = pos + 1
+		if pos+statsSize*MaxPokemon > len(rom) {
+			return -1
+		}
+		ok := true
+		for n := 1; n <= MaxPokemon; n++ {
+			if rom[pos+statsSize*(n-1)] != byte(n) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return int64(pos)
+		}
+	}
+}
+
+func findPalettes(rom []byte) int64 {
+	for i := 0; ; {
+		pos := bytes.Index(rom[i:], unknownPalettes)
+		if pos < 0 {
+			return -1
+		}
+		pos += i
+		i = pos + 1
+		if pos+paletteTableSize > len(rom) {
+			return -1
+		}
+		// Colors are 15 bits, so the high bit of every color must be clear.
+		ok := true
+		for j := pos + 1; j < pos+paletteTableSize; j += 2 {
+			if rom[j]&0x80 != 0 {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return int64(pos)
+		}
+	}
+}
+
+// A picCheck is an entry in a pic pointer table which must decode to an
+// image of the given size.
+type picCheck struct {
+	n    int
+	w, h int
+}
+
+// CheckPicTable reports whether there is a table of n valid far pointers at
+// off, and that the pics named by checks decode successfully.
+func checkPicTable(rom []byte, off int64, n int, crystal bool, checks []picCheck) bool {
+	if off+int64(n)*3 > int64(len(rom)) {
+		return false
+	}
+	for i := 0; i < n; i++ {
+		b := rom[off+int64(i)*3:]
+		if b[2] < 0x40 || b[2] >= 0x80 {
+			return false
+		}
+		p := fixFarPointer(readFarPointer(b), crystal)
+		if p >= int64(len(rom)) {
+			return false
+		}
+	}
+	for _, c := range checks {
+		p := fixFarPointer(readFarPointer(rom[off+int64(c.n)*3:]), crystal)
+		data, err := decodeTiles(bytes.NewReader(rom[p:]), c.w*c.h*8*2)
+		if err != nil || len(data) < c.w*c.h*8*2 {
+			return false
+		}
+	}
+	return true
+}
+
+// A codePattern is a sequence of instructions that refers to a table.
+// Entries of anyByte match any byte, such as the operand being looked for.
+type codePattern []int
+
+const anyByte = -1
+
+// Find returns the position of the first match at or after start, or -1.
+func (p codePattern) find(rom []byte, start int) int {
+	for i := start; i+len(p) <= len(rom); i++ {
+		if p.match(rom[i:]) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (p codePattern) match(b []byte) bool {
+	for i, c := range p {
+		if c != anyByte && b[i] != byte(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// NearOffset converts a pointer found in code at pos to a file offset.
+// Pointers into the switchable bank refer to the bank the code is in.
+func nearOffset(pos int, ptr int) int64 {
+	if ptr < bankSize {
+		return int64(ptr)
+	}
+	return int64(pos)&^(bankSize-1) + int64(ptr)&(bankSize-1)
+}
+
+// FarOffset converts a pointer into the switchable bank to a file offset,
+// or returns -1 if it doesn't point into rom.
+func farOffset(rom []byte, bank int, ptr int) int64 {
+	if ptr < bankSize || ptr >= 2*bankSize {
+		return -1
+	}
+	off := int64(bank)*bankSize + int64(ptr)&(bankSize-1)
+	if bank == 0 || off >= int64(len(rom)) {
+		return -1
+	}
+	return off
+}
+
+func readWord(b []byte) int {
+	return int(b[0]) | int(b[1])<<8
+}
+
+var (
+	// From Pokedex_LoadAnyFootprint:
+	//	ld de, Footprints
+	//	add hl, de
+	//	push hl
+	//	ld e, l
+	//	ld d, h
+	//	ld hl, vTiles2 tile $62
+	//	lb bc, BANK(Footprints), 2
+	footprintCode = codePattern{0x11, anyByte, anyByte, 0x19, 0xE5, 0x5D, 0x54, 0x21, anyByte, anyByte, 0x01, 0x02, anyByte}
+
+	// From ReadMonMenuIcon:
+	//	cp EGG
+	//	jr z, .egg
+	//	dec a
+	//	ld hl, MonMenuIcons
+	//	ld e, a
+	//	ld d, 0
+	//	add hl, de
+	// Crystal follows this with ld a, BANK(MonMenuIcons) and a far read;
+	// Gold and Silver keep the table in the same bank as the code.
+	menuIconsCode = codePattern{0xFE, 0xFD, 0x28, anyByte, 0x3D, 0x21, anyByte, anyByte, 0x5F, 0x16, 0x00, 0x19}
+
+	// From GetIcon:
+	//	ld h, 0
+	//	add hl, hl
+	//	ld de, IconPointers
+	//	add hl, de
+	//	ld a, [hli]
+	//	ld e, a
+	//	ld d, [hl]
+	//	pop hl
+	//	lb bc, BANK(Icons), 8
+	iconPointersCode = codePattern{0x26, 0x00, 0x29, 0x11, anyByte, anyByte, 0x19, 0x2A, 0x5F, 0x56, 0xE1, 0x01, 0x08, anyByte}
+
+	// From GetTrainerBackpic:
+	//	ld b, BANK(ChrisBackpic)
+	//	ld hl, ChrisBackpic
+	//	ld de, vTiles2 tile $31
+	//	ld c, 7 * 7
+	// The Dude's back pic is loaded the same way, but isn't followed
+	// directly by the destination.
+	chrisBackpicCode = codePattern{0x06, anyByte, 0x21, anyByte, anyByte, 0x11, 0x10, 0x93, 0x0E, 0x31}
+
+	// From GetKrisBackpic, which copies the uncompressed pic:
+	//	ld de, KrisBackpic
+	//	ld hl, vTiles2 tile $31
+	//	lb bc, BANK(KrisBackpic), 7 * 7
+	//	call Get2bpp
+	krisBackpicCode = codePattern{0x11, anyByte, anyByte, 0x21, 0x10, 0x93, 0x01, 0x31, anyByte, 0xCD}
+
+	// From GetSprite:
+	//	ld hl, OverworldSprites + SPRITEDATA_ADDR
+	//	dec a
+	//	ld c, a
+	//	ld b, 0
+	//	ld a, NUM_SPRITEDATA_FIELDS
+	//	call AddNTimes
+	// Other routines read single fields the same way, so the lowest
+	// address is the start of the table.
+	overworldCode = codePattern{0x21, anyByte, anyByte, 0x3D, 0x4F, 0x06, 0x00, 0x3E, overworldEntrySize, 0xCD}
+)
+
+// FindFootprints finds the Pokédex footprints, or returns 0.
+func findFootprints(rom []byte) int64 {
+	for i := 0; ; {
+		pos := footprintCode.find(rom, i)
+		if pos < 0 {
+			return 0
+		}
+		i = pos + 1
+		off := farOffset(rom, int(rom[pos+12]), readWord(rom[pos+1:]))
+		if off >= 0 && off+256*32 <= int64(len(rom)) {
+			return off
+		}
+	}
+}
+
+// FindMenuIcons finds the table of menu icon numbers, or returns 0.
+func findMenuIcons(rom []byte) int64 {
+	const ldA = 0x3E
+	for i := 0; ; {
+		pos := menuIconsCode.find(rom, i)
+		if pos < 0 {
+			return 0
+		}
+		i = pos + 1
+		ptr := readWord(rom[pos+6:])
+		var off int64
+		if end := pos + len(menuIconsCode); end+1 < len(rom) && rom[end] == ldA {
+			off = farOffset(rom, int(rom[end+1]), ptr)
+		} else {
+			off = nearOffset(pos, ptr)
+		}
+		if off > 0 && off+MaxPokemon <= int64(len(rom)) {
+			return off
+		}
+	}
+}
+
+// FindIconPointers finds the icon pointer table and the bank the icons are
+// in, or returns zeros.
+func findIconPointers(rom []byte) (off, bank int64) {
+	for i := 0; ; {
+		pos := iconPointersCode.find(rom, i)
+		if pos < 0 {
+			return 0, 0
+		}
+		i = pos + 1
+		off = nearOffset(pos, readWord(rom[pos+4:]))
+		bank = int64(rom[pos+13])
+		if off > 0 && off+2*256 <= int64(len(rom)) && bank != 0 && bank*bankSize < int64(len(rom)) {
+			return off, bank
+		}
+	}
+}
+
+// FindBackpics finds the player's back pics. Chris uses the player palette,
+// which precedes the trainer palettes; Kris's palette isn't known, so her
+// pic is left gray.
+func findBackpics(rom []byte, info RomInfo) map[string]PicInfo {
+	pics := make(map[string]PicInfo)
+	for i := 0; ; {
+		pos := chrisBackpicCode.find(rom, i)
+		if pos < 0 {
+			break
+		}
+		i = pos + 1
+		off := farOffset(rom, int(rom[pos+1]), readWord(rom[pos+3:]))
+		if off < 0 {
+			continue
+		}
+		pic := PicInfo{Offset: off, Width: 6, Height: 6, Compressed: true}
+		if info.TrainerPaletteOffset >= 4 {
+			pic.PaletteOffset = info.TrainerPaletteOffset - 4
+		}
+		pics["chris"] = pic
+		break
+	}
+	for i := 0; ; {
+		pos := krisBackpicCode.find(rom, i)
+		if pos < 0 {
+			break
+		}
+		i = pos + 1
+		off := farOffset(rom, int(rom[pos+8]), readWord(rom[pos+1:]))
+		if off < 0 || off+6*6*16 > int64(len(rom)) {
+			continue
+		}
+		pics["kris"] = PicInfo{Offset: off, Width: 6, Height: 6}
+		break
+	}
+	if len(pics) == 0 {
+		return nil
+	}
+	return pics
+}
+
+// FindOverworld finds the overworld sprite table and counts its entries,
+// or returns zeros.
+func findOverworld(rom []byte) (off int64, n int) {
+	off = -1
+	for i := 0; ; {
+		pos := overworldCode.find(rom, i)
+		if pos < 0 {
+			break
+		}
+		i = pos + 1
+		p := nearOffset(pos, readWord(rom[pos+1:]))
+		if p > 0 && (off < 0 || p < off) {
+			off = p
+		}
+	}
+	if off < 0 {
+		return 0, 0
+	}
+	for ; n < 255; n++ {
+		e := off + int64(n)*overworldEntrySize
+		if e+overworldEntrySize > int64(len(rom)) || !checkOverworldEntry(rom, rom[e:e+overworldEntrySize]) {
+			break
+		}
+	}
+	if n == 0 {
+		return 0, 0
+	}
+	return off, n
+}
+
+// CheckOverworldEntry reports whether e looks like an overworld sprite
+// table entry.
+func checkOverworldEntry(rom []byte, e []byte) bool {
+	size := int64(e[2])
+	kind, palette := e[4], e[5]
+	if kind < walkingSprite || kind > stillSprite || palette >= 8 {
+		return false
+	}
+	if size == 0 || size%(4*16) != 0 {
+		return false
+	}
+	p := farOffset(rom, int(e[3]), readWord(e))
+	return p >= 0 && p+size <= int64(len(rom))
+}
@@ -0,0 +1,93 @@
+package sprites
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// NewScanRom returns a ROM with base stats, palettes, and pic pointer
+// tables at offsets that no real ROM uses.
+func newScanRom() (rom []byte, want RomInfo) {
+	rom = make([]byte, 0x40000)
+	want = RomInfo{
+		StatsOffset:          0x1234,
+		PaletteOffset:        0x3400,
+		TrainerPaletteOffset: 0x3400 + paletteTableSize + 4,
+		SpriteOffset:         0x10000,
+		UnownSpriteOffset:    0x18000,
+		TrainerOffset:        0x24000,
+	}
+
+	for n := 1; n <= MaxPokemon; n++ {
+		stats := rom[want.StatsOffset+statsSize*int64(n-1):]
+		stats[0] = byte(n)
+		stats[spriteSizeIndex] = 0x55
+	}
+	copy(rom[want.StatsOffset:], bulbasaurStats)
+	copy(rom[want.PaletteOffset:], unknownPalettes)
+
+	// Every pic is noise, but the right size.
+	pics := map[int]int64{}
+	off := int64(0x28000)
+	rng := rand.New(rand.NewSource(1))
+	for _, size := range []int{5, 6, 7} {
+		pics[size] = off
+		tiles := make([]byte, size*size*16)
+		rng.Read(tiles)
+		data := encodeTiles(tiles)
+		copy(rom[off:], data)
+		off += int64(len(data))
+	}
+	writeTable := func(table int64, n int, size func(i int) int) {
+		for i := 0; i < n; i++ {
+			p := pics[size(i)]
+			b := rom[table+int64(i)*3:]
+			b[0], b[1], b[2] = byte(p>>14), byte(p), byte(p>>8&0x3F|0x40)
+		}
+	}
+	pokemon := func(i int) int {
+		if i%2 == 1 {
+			return 6 // back pics
+		}
+		return 5
+	}
+	writeTable(want.SpriteOffset, 2*MaxPokemon, pokemon)
+	writeTable(want.UnownSpriteOffset, 2*len(UnownForms), pokemon)
+	writeTable(want.TrainerOffset, MaxTrainer, func(int) int { return 7 })
+	return rom, want
+}
+
+func TestScanRom(t *testing.T) {
+	rom, want := newScanRom()
+	info, err := scanRom(rom, "POKEMON_GLD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want.Title, want.Version = "POKEMON_GLD", "gold"
+	if info.Title != want.Title || info.Version != want.Version ||
+		info.StatsOffset != want.StatsOffset ||
+		info.PaletteOffset != want.PaletteOffset ||
+		info.TrainerPaletteOffset != want.TrainerPaletteOffset ||
+		info.SpriteOffset != want.SpriteOffset ||
+		info.UnownSpriteOffset != want.UnownSpriteOffset ||
+		info.TrainerOffset != want.TrainerOffset {
+		t.Errorf("got %+v, want %+v", info, want)
+	}
+}
+
+func TestScanRomFailure(t *testing.T) {
+	rom, _ := newScanRom()
+	for _, tt := range []struct {
+		name  string
+		rom   []byte
+		title string
+	}{
+		{"blank", make([]byte, len(rom)), "POKEMON_GLD"},
+		{"unknown title", rom, "POKEMON_RED"},
+		{"short", rom[:0x20000], "POKEMON_GLD"},
+	} {
+		if _, err := scanRom(tt.rom, tt.title); err == nil {
+			t.Errorf("%s: scanRom succeeded, want an error", tt.name)
+		}
+	}
+}
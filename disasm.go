@@ -0,0 +1,197 @@
+package sprites
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// A DisasmPic is a pic and its animations, loaded from a directory laid out
+// like gfx/pokemon/<name> in the pokecrystal disassembly.
+type DisasmPic struct {
+	Data   *AnimData
+	Frames []*image.Paletted // frame 0 is the pic itself
+	Shiny  color.Palette     // nil if there is no shiny.pal
+}
+
+// LoadDisasmPic reads front.animated.2bpp (or the compressed
+// front.animated.2bpp.lz), anim.asm, anim_idle.asm, bitmask.asm, frames.asm,
+// normal.pal, and shiny.pal from dir. The tile data is that of the pic
+// followed by the extra tiles used by the frames, as it appears in the ROM.
+// Anim_idle.asm and the palettes are optional.
+//
+// If w and h are zero, the size of the pic in tiles is read from
+// front.dimensions.
+func LoadDisasmPic(dir string, w, h int) (*DisasmPic, error) {
+	if w == 0 && h == 0 {
+		b, err := ioutil.ReadFile(filepath.Join(dir, "front.dimensions"))
+		if err != nil {
+			return nil, err
+		}
+		if len(b) != 1 {
+			return nil, fmt.Errorf("%s: malformed front.dimensions", dir)
+		}
+		w, h = int(b[0]>>4), int(b[0]&0xF)
+	}
+
+	tiledata, err := loadTiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var d AnimData
+	var p DisasmPic
+	palette := defaultPalette
+	for _, t := range []struct {
+		name     string
+		optional bool
+		fn       func(io.Reader) error
+	}{
+		{"anim.asm", false, func(r io.Reader) (err error) { d.Anim, err = ParseAnimScript(r); return }},
+		{"anim_idle.asm", true, func(r io.Reader) (err error) { d.Extra, err = ParseAnimScript(r); return }},
+		{"bitmask.asm", false, func(r io.Reader) (err error) { d.Bitmaps, err = ParseBitmasks(r, w, h); return }},
+		{"frames.asm", false, func(r io.Reader) (err error) { d.Frames, err = ParseFrames(r); return }},
+		{"normal.pal", true, func(r io.Reader) (err error) { palette, err = ParsePalette(r); return }},
+		{"shiny.pal", true, func(r io.Reader) (err error) { p.Shiny, err = ParsePalette(r); return }},
+	} {
+		filename := filepath.Join(dir, t.name)
+		f, err := os.Open(filename)
+		if err != nil {
+			if t.optional && os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		err = t.fn(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", filename, err)
+		}
+	}
+
+	frames, bad, err := d.compose(tiledata, palette, w, h)
+	if err != nil {
+		if bad < 0 {
+			return nil, fmt.Errorf("%s: front pic: %s", dir, err)
+		}
+		return nil, fmt.Errorf("%s: frame %d: %s", dir, bad+1, err)
+	}
+	p.Data = &d
+	p.Frames = frames
+	return &p, nil
+}
+
+// Files holding a pic's tiles in the ROM's layout, in the order loadTiles
+// tries them. In pokecrystal, front.2bpp is the frames stacked one above
+// the other, and front.animated.2bpp is built from it; but older versions
+// of rip wrote the ROM's layout to front.2bpp and front.lz, so those are
+// read the same way if the others are missing.
+var tileFiles = []struct {
+	name       string
+	compressed bool
+}{
+	{"front.animated.2bpp", false},
+	{"front.animated.2bpp.lz", true},
+	{"front.2bpp", false},
+	{"front.lz", true},
+}
+
+// LoadTiles reads the uncompressed tile data for a pic from the first of
+// tileFiles that exists.
+func loadTiles(dir string) ([]byte, error) {
+	for _, f := range tileFiles {
+		filename := filepath.Join(dir, f.name)
+		data, err := ioutil.ReadFile(filename)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil || !f.compressed {
+			return data, err
+		}
+		tiledata, err := decodeTiles(bytes.NewReader(data), len(data)*2)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", filename, err)
+		}
+		return tiledata, nil
+	}
+	return nil, fmt.Errorf("%s: no front.animated.2bpp or front.animated.2bpp.lz", dir)
+}
+
+// Animation interprets anim.asm.
+func (p *DisasmPic) Animation() (*Animation, error) {
+	return animate(p.Data.Anim, p.Frames)
+}
+
+// ExtraAnimation interprets anim_idle.asm.
+func (p *DisasmPic) ExtraAnimation() (*Animation, error) {
+	if p.Data.Extra == nil {
+		return nil, errors.New("no idle animation")
+	}
+	return animate(p.Data.Extra, p.Frames)
+}
+
+// DisasmName returns the name of a Pokémon's directory under gfx/pokemon in
+// the pokecrystal and pokegold disassemblies. Each Unown form has its own
+// directory, unown_a through unown_z; the palettes are in unown.
+func DisasmName(number int, form string) string {
+	if 1 > number || number > MaxPokemon {
+		return ""
+	}
+	if number == 201 && form != "" {
+		return "unown_" + form
+	}
+	return disasmNames[number-1]
+}
+
+var disasmNames = [MaxPokemon]string{
+	"bulbasaur", "ivysaur", "venusaur", "charmander", "charmeleon",
+	"charizard", "squirtle", "wartortle", "blastoise", "caterpie",
+	"metapod", "butterfree", "weedle", "kakuna", "beedrill", "pidgey",
+	"pidgeotto", "pidgeot", "rattata", "raticate", "spearow", "fearow",
+	"ekans", "arbok", "pikachu", "raichu", "sandshrew", "sandslash",
+	"nidoran_f", "nidorina", "nidoqueen", "nidoran_m", "nidorino",
+	"nidoking", "clefairy", "clefable", "vulpix", "ninetales",
+	"jigglypuff", "wigglytuff", "zubat", "golbat", "oddish", "gloom",
+	"vileplume", "paras", "parasect", "venonat", "venomoth", "diglett",
+	"dugtrio", "meowth", "persian", "psyduck", "golduck", "mankey",
+	"primeape", "growlithe", "arcanine", "poliwag", "poliwhirl",
+	"poliwrath", "abra", "kadabra", "alakazam", "machop", "machoke",
+	"machamp", "bellsprout", "weepinbell", "victreebel", "tentacool",
+	"tentacruel", "geodude", "graveler", "golem", "ponyta", "rapidash",
+	"slowpoke", "slowbro", "magnemite", "magneton", "farfetch_d",
+	"doduo", "dodrio", "seel", "dewgong", "grimer", "muk", "shellder",
+	"cloyster", "gastly", "haunter", "gengar", "onix", "drowzee",
+	"hypno", "krabby", "kingler", "voltorb", "electrode", "exeggcute",
+	"exeggutor", "cubone", "marowak", "hitmonlee", "hitmonchan",
+	"lickitung", "koffing", "weezing", "rhyhorn", "rhydon", "chansey",
+	"tangela", "kangaskhan", "horsea", "seadra", "goldeen", "seaking",
+	"staryu", "starmie", "mr__mime", "scyther", "jynx", "electabuzz",
+	"magmar", "pinsir", "tauros", "magikarp", "gyarados", "lapras",
+	"ditto", "eevee", "vaporeon", "jolteon", "flareon", "porygon",
+	"omanyte", "omastar", "kabuto", "kabutops", "aerodactyl", "snorlax",
+	"articuno", "zapdos", "moltres", "dratini", "dragonair", "dragonite",
+	"mewtwo", "mew", "chikorita", "bayleef", "meganium", "cyndaquil",
+	"quilava", "typhlosion", "totodile", "croconaw", "feraligatr",
+	"sentret", "furret", "hoothoot", "noctowl", "ledyba", "ledian",
+	"spinarak", "ariados", "crobat", "chinchou", "lanturn", "pichu",
+	"cleffa", "igglybuff", "togepi", "togetic", "natu", "xatu", "mareep",
+	"flaaffy", "ampharos", "bellossom", "marill", "azumarill",
+	"sudowoodo", "politoed", "hoppip", "skiploom", "jumpluff", "aipom",
+	"sunkern", "sunflora", "yanma", "wooper", "quagsire", "espeon",
+	"umbreon", "murkrow", "slowking", "misdreavus", "unown", "wobbuffet",
+	"girafarig", "pineco", "forretress", "dunsparce", "gligar",
+	"steelix", "snubbull", "granbull", "qwilfish", "scizor", "shuckle",
+	"heracross", "sneasel", "teddiursa", "ursaring", "slugma",
+	"magcargo", "swinub", "piloswine", "corsola", "remoraid",
+	"octillery", "delibird", "mantine", "skarmory", "houndour",
+	"houndoom", "kingdra", "phanpy", "donphan", "porygon2", "stantler",
+	"smeargle", "tyrogue", "hitmontop", "smoochum", "elekid", "magby",
+	"miltank", "blissey", "raikou", "entei", "suicune", "larvitar",
+	"pupitar", "tyranitar", "lugia", "ho_oh", "celebi",
+}
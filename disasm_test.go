@@ -0,0 +1,86 @@
+package sprites
+
+import (
+	"bytes"
+	"image/color"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadDisasmPic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "disasm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tiledata := make([]byte, 27*16)
+	rand.New(rand.NewSource(1)).Read(tiledata)
+	d := &AnimData{
+		Anim:    []byte{0x01, 0x0F, 0x02, 0x08, 0xFF},
+		Bitmaps: [][]byte{{0x01, 0x00, 0x00, 0x01}, {0xC0, 0x01, 0x00, 0x00}},
+		Frames:  [][]byte{{0x00, 0x19, 0x1A}, {0x01, 0x19, 0x1A, 0x19}},
+	}
+	pal := color.Palette{color.White, RGB15(0x1234), RGB15(0x0421), color.Black}
+
+	write := func(name string, fn func(*bytes.Buffer) error) {
+		var buf bytes.Buffer
+		if err := fn(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), buf.Bytes(), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("front.dimensions", func(b *bytes.Buffer) error { return b.WriteByte(0x55) })
+	write("anim.asm", func(b *bytes.Buffer) error { return WriteAnimScript(b, d.Anim) })
+	write("bitmask.asm", func(b *bytes.Buffer) error { return WriteBitmasks(b, d.Bitmaps) })
+	write("frames.asm", func(b *bytes.Buffer) error { return WriteFrames(b, d.Frames) })
+	write("normal.pal", func(b *bytes.Buffer) error { return WritePalette(b, pal) })
+
+	if _, err := LoadDisasmPic(dir, 0, 0); err == nil {
+		t.Error("expected an error for missing tiles")
+	}
+
+	want, _, err := d.compose(tiledata, pal, 5, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range tileFiles {
+		write(f.name, func(b *bytes.Buffer) error {
+			if f.compressed {
+				b.Write(encodeTiles(tiledata))
+			} else {
+				b.Write(tiledata)
+			}
+			return nil
+		})
+		p, err := LoadDisasmPic(dir, 0, 0)
+		os.Remove(filepath.Join(dir, f.name))
+		if err != nil {
+			t.Errorf("%s: %v", f.name, err)
+			continue
+		}
+		if len(p.Frames) != len(want) {
+			t.Errorf("%s: got %d frames, want %d", f.name, len(p.Frames), len(want))
+			continue
+		}
+		for i := range want {
+			if !bytes.Equal(p.Frames[i].Pix, want[i].Pix) {
+				t.Errorf("%s: frame %d differs", f.name, i)
+			}
+		}
+		if p.Frames[0].Palette[1] != pal[1] || p.Frames[0].Palette[2] != pal[2] {
+			t.Errorf("%s: got palette %v, want %v", f.name, p.Frames[0].Palette, pal)
+		}
+		if _, err := p.Animation(); err != nil {
+			t.Errorf("%s: %v", f.name, err)
+		}
+		if _, err := p.ExtraAnimation(); err == nil {
+			t.Errorf("%s: expected an error for a missing anim_idle.asm", f.name)
+		}
+	}
+}
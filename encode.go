@@ -0,0 +1,268 @@
+package sprites
+
+import (
+	"errors"
+	"image"
+)
+
+/*
+
+The compressed format understood by decodeTiles is a list of commands, each of
+which appends some bytes to the output. A command starts with a one-byte
+header holding a 3-bit control code and a 5-bit count, or, if the control
+code is 7, a two-byte header holding a 3-bit control code and a 10-bit count.
+The count is stored minus one. The stream ends with a 0xFF byte.
+
+	0  literal          count bytes follow
+	1  byte fill        one byte, repeated count times
+	2  alternating fill two bytes, repeated alternately
+	3  zero fill        no argument
+	4  copy             a seek; copies bytes from earlier in the output
+	5  reversed copy    a seek; like 4, but each byte is bit-reversed
+	6  backward copy    a seek; copies bytes going backwards
+
+A seek with the high bit set is a single byte giving a distance back from the
+end of the output; otherwise it is a two-byte big-endian absolute position.
+
+Encode finds the shortest possible encoding by working backwards from the end
+of the data, picking the cheapest command at each position.
+
+*/
+
+const (
+	lzLiteral = iota
+	lzFill
+	lzAlternate
+	lzZero
+	lzCopy
+	lzReverse
+	lzBackward
+	lzLong
+)
+
+const (
+	lzMaxCount = 1024
+	lzMaxShort = 32
+	lzMaxNear  = 0x80
+	lzMaxSeek  = 0x7FFF
+)
+
+var (
+	ErrBadSize       = errors.New("image dimensions must be a multiple of 8")
+	ErrTooManyColors = errors.New("image uses more than four colors")
+)
+
+// Encode compresses a GSC image. The image must be a multiple of 8 pixels
+// wide and tall and use only the first four palette entries.
+// The result can be read back with Decode.
+func Encode(m *image.Paletted) ([]byte, error) {
+	w, h := m.Rect.Dx(), m.Rect.Dy()
+	if w%8 != 0 || h%8 != 0 {
+		return nil, ErrBadSize
+	}
+	for y := m.Rect.Min.Y; y < m.Rect.Max.Y; y++ {
+		for _, p := range m.Pix[m.PixOffset(m.Rect.Min.X, y):m.PixOffset(m.Rect.Max.X, y)] {
+			if p > 3 {
+				return nil, ErrTooManyColors
+			}
+		}
+	}
+	data := tile(m)
+	return encodeTiles(data), nil
+}
+
+// Tile is the inverse of untile.
+func tile(m *image.Paletted) []byte {
+	w, h := m.Rect.Dx(), m.Rect.Dy()
+	data := make([]byte, 0, w*h/4)
+	for x := m.Rect.Min.X; x < m.Rect.Min.X+w; x += 8 {
+		for y := m.Rect.Min.Y; y < m.Rect.Min.Y+h; y += 8 {
+			for ty := 0; ty < 8; ty++ {
+				var lo, hi byte
+				for tx := 0; tx < 8; tx++ {
+					p := m.Pix[m.PixOffset(x+tx, y+ty)]
+					lo = lo<<1 | p&1
+					hi = hi<<1 | p>>1&1
+				}
+				data = append(data, lo, hi)
+			}
+		}
+	}
+	return data
+}
+
+// An lzCommand is a single compression command.
+type lzCommand struct {
+	control int
+	count   int
+	seek    int // for copies, the absolute position copied from
+}
+
+func encodeTiles(data []byte) []byte {
+	n := len(data)
+
+	// cost[i] is the number of bytes needed to encode data[i:],
+	// and best[i] is the first command of that encoding.
+	cost := make([]int, n+1)
+	best := make([]lzCommand, n)
+
+	// Positions of each byte value, for finding copy candidates.
+	var where [256][]int
+
+	// Copies can only refer to earlier data, so the candidates for each
+	// position are gathered in a forward pass before the costs are
+	// worked out.
+	copies := make([][3]lzMatch, n)
+	for i := 0; i < n; i++ {
+		copies[i] = findMatches(data, i, where[:])
+		where[data[i]] = append(where[data[i]], i)
+	}
+
+	for i := n - 1; i >= 0; i-- {
+		cost[i] = -1
+		try := func(c lzCommand, argsize int) {
+			k := headerSize(c.count) + argsize + cost[i+c.count]
+			if cost[i] < 0 || k < cost[i] {
+				cost[i] = k
+				best[i] = c
+			}
+		}
+
+		max := n - i
+		if max > lzMaxCount {
+			max = lzMaxCount
+		}
+
+		for k := 1; k <= max; k++ {
+			try(lzCommand{control: lzLiteral, count: k}, k)
+		}
+
+		run := 1
+		for run < max && data[i+run] == data[i] {
+			run++
+		}
+		for k := 1; k <= run; k++ {
+			if data[i] == 0 {
+				try(lzCommand{control: lzZero, count: k}, 0)
+			} else {
+				try(lzCommand{control: lzFill, count: k}, 1)
+			}
+		}
+
+		if max >= 2 {
+			run := 2
+			for run < max && data[i+run] == data[i+run%2] {
+				run++
+			}
+			for k := 2; k <= run; k++ {
+				try(lzCommand{control: lzAlternate, count: k}, 2)
+			}
+		}
+
+		for j, m := range copies[i] {
+			control := lzCopy + j
+			for k := 1; k <= m.far.count; k++ {
+				if k <= m.near.count {
+					try(lzCommand{control: control, count: k, seek: m.near.seek}, 1)
+				} else {
+					try(lzCommand{control: control, count: k, seek: m.far.seek}, 2)
+				}
+			}
+		}
+	}
+
+	out := make([]byte, 0, cost[0]+1)
+	for i := 0; i < n; {
+		c := best[i]
+		out = appendCommand(out, c, data[i:], i)
+		i += c.count
+	}
+	out = append(out, 0xFF)
+	return out
+}
+
+// An lzMatch records the longest copy of each kind available at a position,
+// both overall (far) and within reach of a one-byte seek (near).
+type lzMatch struct {
+	near, far struct{ seek, count int }
+}
+
+// FindMatches returns the longest forward, reversed, and backward copies
+// for data[i:] from data[:i].
+func findMatches(data []byte, i int, where [][]int) (m [3]lzMatch) {
+	max := len(data) - i
+	if max > lzMaxCount {
+		max = lzMaxCount
+	}
+	update := func(m *lzMatch, seek, count int) {
+		if count > m.far.count {
+			m.far.seek, m.far.count = seek, count
+		}
+		if i-seek-1 < lzMaxNear && count > m.near.count {
+			m.near.seek, m.near.count = seek, count
+		}
+	}
+
+	for _, s := range where[data[i]] {
+		if s > lzMaxSeek {
+			break
+		}
+		// Forward copy. The source may overlap the destination.
+		k := 1
+		for k < max && data[s+k] == data[i+k] {
+			k++
+		}
+		update(&m[0], s, k)
+
+		// Backward copy.
+		k = 1
+		for k < max && k <= s && data[s-k] == data[i+k] {
+			k++
+		}
+		update(&m[2], s, k)
+	}
+
+	for _, s := range where[reverse(data[i])] {
+		if s > lzMaxSeek {
+			break
+		}
+		k := 1
+		for k < max && reverse(data[s+k]) == data[i+k] {
+			k++
+		}
+		update(&m[1], s, k)
+	}
+	return m
+}
+
+func headerSize(count int) int {
+	if count > lzMaxShort {
+		return 2
+	}
+	return 1
+}
+
+func appendCommand(out []byte, c lzCommand, data []byte, pos int) []byte {
+	n := c.count - 1
+	if c.count > lzMaxShort {
+		out = append(out, byte(lzLong<<5|c.control<<2|n>>8), byte(n))
+	} else {
+		out = append(out, byte(c.control<<5|n))
+	}
+	switch c.control {
+	case lzLiteral:
+		out = append(out, data[:c.count]...)
+	case lzFill:
+		out = append(out, data[0])
+	case lzAlternate:
+		out = append(out, data[0], data[1])
+	case lzZero:
+	case lzCopy, lzReverse, lzBackward:
+		if d := pos - c.seek - 1; d < lzMaxNear {
+			out = append(out, byte(0x80|d))
+		} else {
+			out = append(out, byte(c.seek>>8), byte(c.seek))
+		}
+	}
+	return out
+}
@@ -0,0 +1,105 @@
+package sprites
+
+import (
+	"bytes"
+	"image"
+	"math/rand"
+	"testing"
+)
+
+func TestEncodeRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, size := range []image.Point{{1, 1}, {5, 5}, {6, 6}, {7, 7}, {3, 9}} {
+		for _, ncolors := range []int{1, 2, 4} {
+			m := image.NewPaletted(image.Rect(0, 0, size.X*8, size.Y*8), defaultPalette)
+			// Runs of colors, so that there is something to compress.
+			for i := 0; i < len(m.Pix); {
+				c := uint8(rng.Intn(ncolors))
+				for n := rng.Intn(12); n >= 0 && i < len(m.Pix); n-- {
+					m.Pix[i] = c
+					i++
+				}
+			}
+			data, err := Encode(m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Decode(bytes.NewReader(data), size.X, size.Y)
+			if err != nil {
+				t.Errorf("%dx%d, %d colors: decode error: %v", size.X, size.Y, ncolors, err)
+				continue
+			}
+			if !bytes.Equal(got.Pix, m.Pix) {
+				t.Errorf("%dx%d, %d colors: image doesn't round-trip", size.X, size.Y, ncolors)
+			}
+		}
+	}
+}
+
+func TestEncodeTiles(t *testing.T) {
+	lit := []byte{0x12, 0x34, 0x56, 0x78, 0x9A, 0xBC, 0xDE, 0xF1, 0x23, 0x45}
+	var data []byte
+	data = append(data, lit...)
+	data = append(data, bytes.Repeat([]byte{0x55}, 20)...)
+	data = append(data, bytes.Repeat([]byte{0x0F, 0xF0}, 10)...)
+	data = append(data, make([]byte, 20)...)
+	data = append(data, lit...)
+	for _, b := range lit {
+		data = append(data, reverse(b))
+	}
+	for i := range lit {
+		data = append(data, lit[len(lit)-1-i])
+	}
+	// Long enough to need a two-byte header.
+	for i := 0; i < 100; i++ {
+		data = append(data, byte(i*37+11))
+	}
+
+	enc := encodeTiles(data)
+	got, err := decodeTiles(bytes.NewReader(enc), len(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("decodeTiles(encodeTiles(x)) != x\ngot  %x\nwant %x", got, data)
+	}
+
+	// Walk the commands to check that every kind was used.
+	var seen [7]bool
+	for i := 0; enc[i] != 0xFF; {
+		control, count := int(enc[i]>>5), int(enc[i]&0x1F)+1
+		i++
+		if control == lzLong {
+			control = int(enc[i-1]>>2) & 7
+			count = int(enc[i-1]&3)<<8 + int(enc[i]) + 1
+			i++
+		}
+		seen[control] = true
+		switch control {
+		case lzLiteral:
+			i += count
+		case lzFill:
+			i++
+		case lzAlternate:
+			i += 2
+		case lzCopy, lzReverse, lzBackward:
+			if enc[i]&0x80 != 0 {
+				i++
+			} else {
+				i += 2
+			}
+		}
+	}
+	for control, ok := range seen {
+		if !ok {
+			t.Errorf("command %d not used", control)
+		}
+	}
+}
+
+func TestEncodeBadSize(t *testing.T) {
+	m := image.NewPaletted(image.Rect(0, 0, 12, 16), defaultPalette)
+	if _, err := Encode(m); err != ErrBadSize {
+		t.Errorf("got %v, want %v", err, ErrBadSize)
+	}
+}
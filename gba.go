@@ -13,6 +13,10 @@ import (
 	"io"
 	"os"
 	"strconv"
+	"strings"
+
+	"github.com/magical/sprites/lcd"
+	"github.com/magical/sprites/montage"
 )
 
 var errMalformed = errors.New("malformed data")
@@ -161,8 +165,32 @@ func Palette(r io.ByteReader) (color.Palette, error) {
 	return pal, nil
 }
 
+var (
+	colorCorrection = flag.String("color-correction", "none", "adjust colors to look as they would on a screen: "+strings.Join(lcd.Names(), ", "))
+	montageFlag     = flag.Bool("montage", false, "draw every Pokémon as a single montage instead of ripping one")
+	columns         = flag.Int("columns", 15, "number of columns in a montage")
+)
+
+const maxNational = 386
+
+// RipPokemon returns the front sprite of the Pokémon with the given
+// internal number.
+func ripPokemon(f *os.File, number int) (*image.Paletted, error) {
+	f.Seek(readPointerAt(f, info.PaletteOffset, number*2), 0)
+	pal, err := Palette(bufio.NewReader(f))
+	if err != nil {
+		return nil, err
+	}
+	f.Seek(readPointerAt(f, info.SpriteOffset, number*2), 0)
+	return Sprite(bufio.NewReader(f), pal, 64, 64)
+}
+
 func main() {
 	flag.Parse()
+	correction, err := lcd.Lookup(*colorCorrection)
+	if err != nil {
+		panic(err)
+	}
 	f, err := os.Open(flag.Arg(0))
 	if err != nil {
 		panic(err)
@@ -180,21 +208,31 @@ func main() {
 		idmap[n] = i
 	}
 
+	if *montageFlag {
+		pics := make([]image.Image, maxNational)
+		for n := 1; n <= maxNational; n++ {
+			i, ok := idmap[n]
+			if !ok {
+				continue
+			}
+			if m, err := ripPokemon(f, i); err == nil {
+				m.Palette = correction.Palette(m.Palette)
+				pics[n-1] = m
+			}
+		}
+		png.Encode(os.Stdout, montage.Draw(pics, &montage.Options{Columns: *columns}))
+		return
+	}
+
 	var ok bool
 	number, ok = idmap[number]
 	if !ok {
 		panic("bad number")
 	}
-
-	f.Seek(readPointerAt(f, info.PaletteOffset, number*2), 0)
-	pal, err := Palette(bufio.NewReader(f))
-	if err != nil {
-		panic(err)
-	}
-	f.Seek(readPointerAt(f, info.SpriteOffset, number*2), 0)
-	m, err := Sprite(bufio.NewReader(f), pal, 64, 64)
+	m, err := ripPokemon(f, number)
 	if err != nil {
 		panic(err)
 	}
+	m.Palette = correction.Palette(m.Palette)
 	png.Encode(os.Stdout, m)
 }
@@ -3,12 +3,14 @@ package sprites
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"image"
 	"image/color"
-	"image/gif"
 	"io"
+	"io/ioutil"
 	//"log"
 	"strings"
 )
@@ -43,8 +45,26 @@ var (
 	ErrTooSmall      = errors.New("decompressed data is too short")
 	ErrNoSuchPokemon = errors.New("no such Pokémon")
 	ErrNoSuchTrainer = errors.New("no such trainer")
+	ErrNoSuchSprite  = errors.New("no such sprite")
+	ErrNoSuchSystem  = errors.New("no palettes for that system")
+	ErrNotFound      = errors.New("table not found in ROM")
 )
 
+// A TableError records a failure to read an entry in one of the ROM's
+// tables, or the data it points to.
+type TableError struct {
+	Table  string // name of the table, e.g. "stats" or "sprite"
+	Index  int    // index of the entry in the table
+	Offset int64  // file offset of the data that couldn't be read
+	Err    error
+}
+
+func (e *TableError) Error() string {
+	return fmt.Sprintf("%s table entry %d at %#x: %s", e.Table, e.Index, e.Offset, e.Err)
+}
+
+func (e *TableError) Unwrap() error { return e.Err }
+
 var UnownForms = []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l", "m", "n", "o", "p", "q", "r", "s", "t", "u", "v", "w", "x", "y", "z"}
 
 // Decode a GSC image of dimensions w*8 x h*8.
@@ -80,7 +100,7 @@ func decodeTiles(r io.ByteReader, sizehint int) ([]byte, error) {
 	for {
 		var control, num, seek int
 		num = int(readByte())
-		if num == 0xFF {
+		if num == 0xFF || readErr != nil {
 			break
 		}
 		if len(data) > 0xFFFF {
@@ -154,10 +174,11 @@ func decodeTiles(r io.ByteReader, sizehint int) ([]byte, error) {
 	return data, readErr
 }
 
+// Untile fills m with 2bpp tiles, stored in column-major order.
 func untile(m *image.Paletted, data []byte) {
-	w, h := m.Rect.Dx(), m.Rect.Dy()
-	for i, x := 0, 0; x < w; x += 8 {
-		for y := 0; y < h; y += 8 {
+	r := m.Rect
+	for i, x := 0, r.Min.X; x < r.Max.X; x += 8 {
+		for y := r.Min.Y; y < r.Max.Y; y += 8 {
 			for ty := 0; ty < 8; ty++ {
 				pix := mingle(uint16(data[i]), uint16(data[i+1]))
 				for tx := 7; tx >= 0; tx-- {
@@ -190,27 +211,58 @@ func mingle(x, y uint16) uint16 {
 	return x | y<<1
 }
 
-type romInfo struct {
-	Title                string
-	Version              string
-	StatsOffset          int64
-	PaletteOffset        int64
-	SpriteOffset         int64
-	UnownSpriteOffset    int64
-	TrainerOffset        int64
-	TrainerPaletteOffset int64
+// A RomInfo describes where the sprite data is in a particular ROM.
+// Offsets are file offsets; an offset of zero means the table isn't present.
+type RomInfo struct {
+	Title                string `json:"title"`
+	Version              string `json:"version"`
+	StatsOffset          int64  `json:"stats_offset"`
+	PaletteOffset        int64  `json:"palette_offset"`
+	SpriteOffset         int64  `json:"sprite_offset"`
+	UnownSpriteOffset    int64  `json:"unown_sprite_offset,omitempty"`
+	TrainerOffset        int64  `json:"trainer_offset,omitempty"`
+	TrainerPaletteOffset int64  `json:"trainer_palette_offset,omitempty"`
+
+	AnimOffset         int64 `json:"anim_offset,omitempty"`
+	ExtraOffset        int64 `json:"extra_offset,omitempty"`
+	FramesOffset       int64 `json:"frames_offset,omitempty"`
+	BitmapsOffset      int64 `json:"bitmaps_offset,omitempty"`
+	UnownAnimOffset    int64 `json:"unown_anim_offset,omitempty"`
+	UnownExtraOffset   int64 `json:"unown_extra_offset,omitempty"`
+	UnownFramesOffset  int64 `json:"unown_frames_offset,omitempty"`
+	UnownBitmapsOffset int64 `json:"unown_bitmaps_offset,omitempty"`
+
+	// Party menu icons are found through the icon number of each Pokémon,
+	// which indexes a table of near pointers into IconBank. If these or
+	// FootprintOffset are zero, NewRipper looks for them.
+	MenuIconsOffset    int64 `json:"menu_icons_offset,omitempty"`
+	IconPointersOffset int64 `json:"icon_pointers_offset,omitempty"`
+	IconBank           int64 `json:"icon_bank,omitempty"`
+	FootprintOffset    int64 `json:"footprint_offset,omitempty"`
+
+	// Pics which are loaded by code rather than through a table, such as
+	// the player's back pic, by name. If these or OverworldOffset are zero,
+	// NewRipper looks for them.
+	Backpics map[string]PicInfo `json:"backpics,omitempty"`
+
+	OverworldOffset int64 `json:"overworld_offset,omitempty"`
+	NumOverworld    int   `json:"num_overworld,omitempty"`
+
+	// Text tables. If these are zero, NewRipper looks for them.
+	PokemonNamesOffset      int64 `json:"pokemon_names_offset,omitempty"`
+	TrainerClassNamesOffset int64 `json:"trainer_class_names_offset,omitempty"`
+}
 
-	AnimOffset         int64
-	ExtraOffset        int64
-	FramesOffset       int64
-	BitmapsOffset      int64
-	UnownAnimOffset    int64
-	UnownExtraOffset   int64
-	UnownFramesOffset  int64
-	UnownBitmapsOffset int64
+// A PicInfo gives the location of a single pic.
+type PicInfo struct {
+	Offset        int64 `json:"offset"`
+	Width         int   `json:"width"`  // in tiles
+	Height        int   `json:"height"` // in tiles
+	Compressed    bool  `json:"compressed"`
+	PaletteOffset int64 `json:"palette_offset,omitempty"` // two colors, as in the palette table
 }
 
-var romtab = map[string]romInfo{
+var romtab = map[string]RomInfo{
 	"POKEMON_GLD": {
 		Title:                "POKEMON_GLD",
 		Version:              "gold",
@@ -260,60 +312,113 @@ var defaultPalette = color.Palette{
 	color.Gray{0},
 }
 
+// GrayPalette is the original Game Boy's four shades, evenly spaced. The
+// rby package uses the same one.
+var grayPalette = color.Palette{
+	color.Gray{255},
+	color.Gray{170},
+	color.Gray{85},
+	color.Gray{0},
+}
+
+// Reader is the interface that NewRipper used to require. It is kept so
+// that code which refers to it still compiles; NewRipper now accepts any
+// io.Reader, since it reads the whole ROM into memory.
 type Reader interface {
 	io.Reader
 	io.ReaderAt
 	io.Seeker
 }
 
+// A Ripper extracts sprites from a Gold, Silver, or Crystal ROM.
+// The whole ROM is held in memory, so a Ripper is safe for concurrent use
+// by multiple goroutines.
 type Ripper struct {
-	r    Reader
-	buf  *bufferedReader
-	info romInfo
+	rom  []byte
+	r    *bytes.Reader
+	info RomInfo
 }
 
-func NewRipper(r Reader) (_ *Ripper, err error) {
-	rip := new(Ripper)
-	rip.r = r
-	rip.buf = newBufferedReader(r)
-
-	// Check that the reader is seekable. After this we will assume that all
-	// seeks succeed.
-	if _, err := r.Seek(0, 0); err != nil {
+// NewRipper reads a ROM from r and identifies it.
+func NewRipper(r io.Reader) (_ *Ripper, err error) {
+	rom, err := ioutil.ReadAll(r)
+	if err != nil {
 		return nil, err
 	}
+	if len(rom) < 0x150 {
+		return nil, errors.New("Couldn't recognize ROM")
+	}
+	rip := new(Ripper)
+	rip.rom = rom
+	rip.r = bytes.NewReader(rom)
 
-	var header [0x150]byte
-	r.ReadAt(header[:], 0)
+	header := rom[:0x150]
 	title := string(header[0x134:0x13F])
 	title = strings.TrimRight(title, "\x00")
-
-	info, ok := romtab[title]
-	if !ok {
-		return nil, errors.New("Couldn't recognize ROM")
+	size := int64(len(rom))
+
+	// Registered profiles are trusted, as long as they fit in the ROM.
+	// Otherwise use the known offsets if they look right, or else go
+	// looking for the tables.
+	info, ok := profiles[title]
+	if ok {
+		if err := info.Validate(size); err != nil {
+			return nil, err
+		}
+	} else if info, ok = romtab[title]; !ok || info.Validate(size) != nil || !checkInfo(rip.r, info) {
+		info, err = scanRom(rom, title)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if info.PokemonNamesOffset == 0 {
+		info.PokemonNamesOffset = findPokemonNames(rom)
+	}
+	if info.TrainerClassNamesOffset == 0 {
+		info.TrainerClassNamesOffset = findTrainerClassNames(rom)
+	}
+	if info.MenuIconsOffset == 0 {
+		info.MenuIconsOffset = findMenuIcons(rom)
+	}
+	if info.IconPointersOffset == 0 || info.IconBank == 0 {
+		info.IconPointersOffset, info.IconBank = findIconPointers(rom)
+	}
+	if info.FootprintOffset == 0 {
+		info.FootprintOffset = findFootprints(rom)
+	}
+	if info.Backpics == nil {
+		info.Backpics = findBackpics(rom, info)
+	}
+	if info.OverworldOffset == 0 || info.NumOverworld == 0 {
+		info.OverworldOffset, info.NumOverworld = findOverworld(rom)
 	}
 	rip.info = info
 
 	return rip, nil
 }
 
-// BufferedReader is a *bufio.Reader that can Seek.
-type bufferedReader struct {
-	r io.ReadSeeker
-	*bufio.Reader
-}
-
-func newBufferedReader(r io.ReadSeeker) *bufferedReader {
-	return &bufferedReader{r, bufio.NewReader(r)}
+// At returns a reader for the ROM starting at off.
+func (rip *Ripper) at(off int64) *bytes.Reader {
+	if off < 0 || off > int64(len(rip.rom)) {
+		return bytes.NewReader(nil)
+	}
+	return bytes.NewReader(rip.rom[off:])
 }
 
-// Seek sets the position of the underlying reader and resets the buffer.
-func (b *bufferedReader) Seek(off int64, whence int) {
-	b.r.Seek(off, whence)
-	b.Reset(b.r)
+// ReadScriptAt reads a 0xFF-terminated animation script at off.
+func (rip *Ripper) readScriptAt(off int64) ([]byte, error) {
+	if off < 0 || off >= int64(len(rip.rom)) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	data := rip.rom[off:]
+	n := bytes.IndexByte(data, 0xFF) + 1
+	if n == 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return data[:n:n], nil
 }
 
-func (rip *Ripper) pokemonSize(number int) (width, height int) {
+func (rip *Ripper) pokemonSize(number int) (width, height int, err error) {
 	// Read the base stats structure. We only care about SpriteSize, but
 	// what the heck.
 	var stats struct {
@@ -335,14 +440,13 @@ func (rip *Ripper) pokemonSize(number int) (width, height int) {
 	}
 	size := int64(binary.Size(&stats))
 	off := rip.info.StatsOffset + size*int64(number-1)
-	err := binary.Read(
+	err = binary.Read(
 		io.NewSectionReader(rip.r, off, size),
 		binary.LittleEndian,
 		&stats,
 	)
 	if err != nil {
-		// BUG: shouldn't panic
-		panic(err)
+		return 0, 0, &TableError{"stats", number - 1, off, noEOF(err)}
 	}
 
 	// The high and low nibbles of SpriteSize give the width and height of
@@ -354,20 +458,67 @@ func (rip *Ripper) pokemonSize(number int) (width, height int) {
 	return
 }
 
-// PokemonPalette returns the color palette for a Pokémon,
-// or nil if there is an error.
+// PokemonPalette returns the color palette for a Pokémon, or nil if the
+// number is out of range or the palette can't be read. Use
+// PokemonPaletteFor with "gbc" to get the error instead.
 func (rip *Ripper) PokemonPalette(number int) color.Palette {
 	if 1 > number || number > MaxPokemon {
 		return nil
 	}
-	return rip.pokemonPalette(number, normal)
+	pal, _ := rip.pokemonPalette(number, normal)
+	return pal
 }
 
+// ShinyPalette returns the shiny color palette for a Pokémon, or nil if the
+// number is out of range or the palette can't be read.
 func (rip *Ripper) ShinyPalette(number int) color.Palette {
 	if 1 > number || number > MaxPokemon {
 		return nil
 	}
-	return rip.pokemonPalette(number, shiny)
+	pal, _ := rip.pokemonPalette(number, shiny)
+	return pal
+}
+
+// HasSGB reports whether the game has Super Game Boy support.
+// Gold and Silver do; Crystal doesn't.
+func (rip *Ripper) HasSGB() bool {
+	return rip.rom[0x146] == 3
+}
+
+// HasDMG reports whether the game runs on the original Game Boy.
+// Gold and Silver do; Crystal only runs on the Game Boy Color.
+func (rip *Ripper) HasDMG() bool {
+	return rip.rom[0x143] != 0xC0
+}
+
+// PokemonPaletteFor returns the palette a Pokémon is shown in on a
+// particular system: "gb" for the original Game Boy, which only has four
+// shades of gray, "sgb" for the Super Game Boy, or "gbc" for the Game Boy
+// Color. It returns ErrNoSuchSystem if the game doesn't support the system.
+//
+// Unlike Red and Blue, Gold and Silver don't have separate Super Game Boy
+// palettes. The SGB code loads a Pokémon's colors from the same table as
+// the GBC code and sends them to the SGB with white and black on either
+// side, so the two palettes are the same.
+func (rip *Ripper) PokemonPaletteFor(number int, system string) (color.Palette, error) {
+	if 1 > number || number > MaxPokemon {
+		return nil, ErrNoSuchPokemon
+	}
+	switch system {
+	case "gb":
+		if !rip.HasDMG() {
+			return nil, ErrNoSuchSystem
+		}
+		return append(color.Palette(nil), grayPalette...), nil
+	case "sgb":
+		if !rip.HasSGB() {
+			return nil, ErrNoSuchSystem
+		}
+		return rip.pokemonPalette(number, normal)
+	case "gbc":
+		return rip.pokemonPalette(number, normal)
+	}
+	return nil, ErrNoSuchSystem
 }
 
 const (
@@ -375,26 +526,27 @@ const (
 	shiny  = 1
 )
 
-func (rip *Ripper) pokemonPalette(number int, shiny int) color.Palette {
-	return ripPalette(rip.r, rip.info.PaletteOffset, number*2+shiny)
+func (rip *Ripper) pokemonPalette(number int, shiny int) (color.Palette, error) {
+	return ripPalette(rip.r, "palette", rip.info.PaletteOffset, number*2+shiny)
 }
 
-func (rip *Ripper) trainerPalette(number int) color.Palette {
-	return ripPalette(rip.r, rip.info.TrainerPaletteOffset, number)
+func (rip *Ripper) trainerPalette(number int) (color.Palette, error) {
+	return ripPalette(rip.r, "trainer palette", rip.info.TrainerPaletteOffset, number)
 }
 
-func ripPalette(r io.ReaderAt, off int64, n int) color.Palette {
+func ripPalette(r io.ReaderAt, table string, off int64, n int) (color.Palette, error) {
 	var palette [4]byte
-	_, err := r.ReadAt(palette[:], off+int64(n)*int64(len(palette)))
+	off += int64(n) * int64(len(palette))
+	_, err := r.ReadAt(palette[:], off)
 	if err != nil {
-		return nil
+		return nil, &TableError{table, n, off, noEOF(err)}
 	}
 	return color.Palette{
 		color.White,
 		RGB15(int16(palette[0]) + int16(palette[1])<<8),
 		RGB15(int16(palette[2]) + int16(palette[3])<<8),
 		color.Black,
-	}
+	}, nil
 }
 
 type RGB15 uint16
@@ -411,31 +563,18 @@ func (rip *Ripper) Pokemon(number int) (m *image.Paletted, err error) {
 	if 1 > number || number > MaxPokemon {
 		return nil, ErrNoSuchPokemon
 	}
-	w, h := rip.pokemonSize(number)
-	off := rip.pokemonOffset(number, 0, front)
-	pal := rip.pokemonPalette(number, normal)
-	//log.Printf("Ripping sprite %d, size %dx%d, offset %x", number, w, h, off)
-	rip.buf.Seek(off, 0)
-	m, err = Decode(rip.buf, w, h)
-	if m != nil {
-		m.Palette = pal
+	w, h, err := rip.pokemonSize(number)
+	if err != nil {
+		return nil, err
 	}
-	return
+	return rip.pokemonPic(number, 0, front, w, h)
 }
 
 func (rip *Ripper) PokemonBack(number int) (m *image.Paletted, err error) {
 	if 1 > number || number > MaxPokemon {
 		return nil, ErrNoSuchPokemon
 	}
-	w, h := 6, 6
-	off := rip.pokemonOffset(number, 0, back)
-	pal := rip.pokemonPalette(number, normal)
-	rip.buf.Seek(off, 0)
-	m, err = Decode(rip.buf, w, h)
-	if m != nil {
-		m.Palette = pal
-	}
-	return
+	return rip.pokemonPic(number, 0, back, 6, 6)
 }
 
 func (rip *Ripper) Unown(form string) (m *image.Paletted, err error) {
@@ -443,15 +582,11 @@ func (rip *Ripper) Unown(form string) (m *image.Paletted, err error) {
 		return nil, ErrNoSuchPokemon
 	}
 	formi := int(form[0] - 'a')
-	w, h := rip.pokemonSize(201)
-	off := rip.pokemonOffset(201, formi, front)
-	pal := rip.pokemonPalette(201, normal)
-	rip.buf.Seek(off, 0)
-	m, err = Decode(rip.buf, w, h)
-	if m != nil {
-		m.Palette = pal
+	w, h, err := rip.pokemonSize(201)
+	if err != nil {
+		return nil, err
 	}
-	return
+	return rip.pokemonPic(201, formi, front, w, h)
 }
 
 func (rip *Ripper) UnownBack(form string) (m *image.Paletted, err error) {
@@ -459,15 +594,129 @@ func (rip *Ripper) UnownBack(form string) (m *image.Paletted, err error) {
 		return nil, ErrNoSuchPokemon
 	}
 	formi := int(form[0] - 'a')
-	w, h := 6, 6
-	off := rip.pokemonOffset(201, formi, back)
-	pal := rip.pokemonPalette(201, normal)
-	rip.buf.Seek(off, 0)
-	m, err = Decode(rip.buf, w, h)
-	if m != nil {
-		m.Palette = pal
+	return rip.pokemonPic(201, formi, back, 6, 6)
+}
+
+// PokemonForDVs returns the front pic of an individual Pokémon with the
+// given DVs (determinant values, 0-15), as the game would show it:
+// Unown's form and whether the Pokémon is shiny both depend on its DVs.
+// A shiny Pokémon's pic has the shiny palette.
+func (rip *Ripper) PokemonForDVs(number int, atk, def, spd, spc int) (m *image.Paletted, err error) {
+	for _, dv := range [...]int{atk, def, spd, spc} {
+		if 0 > dv || dv > 15 {
+			return nil, fmt.Errorf("DV out of range: %d", dv)
+		}
 	}
-	return
+	if number == 201 {
+		m, err = rip.Unown(UnownForm(atk, def, spd, spc))
+	} else {
+		m, err = rip.Pokemon(number)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if IsShiny(atk, def, spd, spc) {
+		m.Palette, err = rip.pokemonPalette(number, shiny)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// IsShiny reports whether a Pokémon with the given DVs is shiny.
+// Its defense, speed, and special DVs must all be 10,
+// and its attack DV must be 2, 3, 6, 7, 10, 11, 14, or 15.
+func IsShiny(atk, def, spd, spc int) bool {
+	return def == 10 && spd == 10 && spc == 10 && atk&2 != 0
+}
+
+// UnownForm returns the form of an Unown with the given DVs.
+// The second and third bits of each DV are put together into a byte,
+// which is divided by 10 to get the letter.
+func UnownForm(atk, def, spd, spc int) string {
+	n := (atk&6)<<5 | (def&6)<<3 | (spd&6)<<1 | (spc&6)>>1
+	return UnownForms[n/10]
+}
+
+// A RawPic is a pic as it is stored in the ROM.
+type RawPic struct {
+	Width, Height int    // in tiles
+	Compressed    []byte // compressed data, including the final 0xFF
+	Tiles         []byte // decompressed tile data, including any extra tiles used by the animations
+}
+
+func (rip *Ripper) RawPokemon(number int) (*RawPic, error) {
+	if 1 > number || number > MaxPokemon {
+		return nil, ErrNoSuchPokemon
+	}
+	w, h, err := rip.pokemonSize(number)
+	if err != nil {
+		return nil, err
+	}
+	return rip.rawPic(number, 0, front, w, h)
+}
+
+func (rip *Ripper) RawPokemonBack(number int) (*RawPic, error) {
+	if 1 > number || number > MaxPokemon {
+		return nil, ErrNoSuchPokemon
+	}
+	return rip.rawPic(number, 0, back, 6, 6)
+}
+
+func (rip *Ripper) RawUnown(form string) (*RawPic, error) {
+	if len(form) != 1 || 'a' > form[0] || form[0] > 'z' {
+		return nil, ErrNoSuchPokemon
+	}
+	w, h, err := rip.pokemonSize(201)
+	if err != nil {
+		return nil, err
+	}
+	return rip.rawPic(201, int(form[0]-'a'), front, w, h)
+}
+
+func (rip *Ripper) RawUnownBack(form string) (*RawPic, error) {
+	if len(form) != 1 || 'a' > form[0] || form[0] > 'z' {
+		return nil, ErrNoSuchPokemon
+	}
+	return rip.rawPic(201, int(form[0]-'a'), back, 6, 6)
+}
+
+func (rip *Ripper) rawPic(number, form, facing, w, h int) (*RawPic, error) {
+	table, base, n := rip.pokemonPointer(number, form, facing)
+	off, err := rip.farPointer(table, base, n)
+	if err != nil {
+		return nil, err
+	}
+	r := rip.at(off)
+	tiles, err := decodeTiles(r, w*h*8*2)
+	if err != nil {
+		return nil, &TableError{table, n, off, err}
+	}
+	if len(tiles) < w*h*8*2 {
+		return nil, &TableError{table, n, off, ErrTooSmall}
+	}
+	end := off + r.Size() - int64(r.Len())
+	return &RawPic{w, h, rip.rom[off:end:end], tiles}, nil
+}
+
+func (rip *Ripper) pokemonPic(number, form, facing, w, h int) (*image.Paletted, error) {
+	table, base, n := rip.pokemonPointer(number, form, facing)
+	off, err := rip.farPointer(table, base, n)
+	if err != nil {
+		return nil, err
+	}
+	pal, err := rip.pokemonPalette(number, normal)
+	if err != nil {
+		return nil, err
+	}
+	//log.Printf("Ripping sprite %d, size %dx%d, offset %x", number, w, h, off)
+	m, err := rip.decode(off, w, h)
+	if err != nil {
+		return nil, &TableError{table, n, off, err}
+	}
+	m.Palette = pal
+	return m, nil
 }
 
 func (rip *Ripper) Trainer(number int) (m *image.Paletted, err error) {
@@ -475,14 +724,24 @@ func (rip *Ripper) Trainer(number int) (m *image.Paletted, err error) {
 		return nil, ErrNoSuchTrainer
 	}
 	w, h := 7, 7
-	off := rip.farPointer(rip.info.TrainerOffset, number-1)
-	pal := rip.trainerPalette(number - 1)
-	rip.buf.Seek(off, 0)
-	m, err = Decode(rip.buf, w, h)
-	if m != nil {
-		m.Palette = pal
+	off, err := rip.farPointer("trainer", rip.info.TrainerOffset, number-1)
+	if err != nil {
+		return nil, err
 	}
-	return
+	pal, err := rip.trainerPalette(number - 1)
+	if err != nil {
+		return nil, err
+	}
+	m, err = rip.decode(off, w, h)
+	if err != nil {
+		return nil, &TableError{"trainer", number - 1, off, err}
+	}
+	m.Palette = pal
+	return m, nil
+}
+
+func (rip *Ripper) decode(off int64, w, h int) (*image.Paletted, error) {
+	return Decode(rip.at(off), w, h)
 }
 
 func (rip *Ripper) HasAnimations() bool {
@@ -493,7 +752,22 @@ func (rip *Ripper) Version() string {
 	return rip.info.Version
 }
 
-func (rip *Ripper) PokemonAnimation(number int) (g *gif.GIF, err error) {
+// Profile returns the offsets the Ripper is using.
+func (rip *Ripper) Profile() RomInfo {
+	return rip.info
+}
+
+func (rip *Ripper) PokemonAnimation(number int) (*Animation, error) {
+	return rip.pokemonAnimation(number, "anim", rip.info.AnimOffset)
+}
+
+// PokemonExtraAnimation returns the idle animation which Crystal plays
+// on the status screen.
+func (rip *Ripper) PokemonExtraAnimation(number int) (*Animation, error) {
+	return rip.pokemonAnimation(number, "extra", rip.info.ExtraOffset)
+}
+
+func (rip *Ripper) pokemonAnimation(number int, table string, base int64) (*Animation, error) {
 	if 1 > number || number > MaxPokemon {
 		return nil, ErrNoSuchPokemon
 	}
@@ -503,17 +777,24 @@ func (rip *Ripper) PokemonAnimation(number int) (g *gif.GIF, err error) {
 		return nil, err
 	}
 
-	rip.buf.Seek(readNearPointerAt(rip.r, rip.info.AnimOffset, number-1), 0)
-	animdata, err := rip.buf.ReadBytes('\xFF')
+	animdata, err := rip.readScript(table, base, number-1)
 	if err != nil {
 		return nil, err
 	}
 
-	g = animate(animdata, frames)
-	return g, err
+	return animate(animdata, frames)
+}
+
+func (rip *Ripper) UnownAnimation(form string) (*Animation, error) {
+	return rip.unownAnimation(form, "unown anim", rip.info.UnownAnimOffset)
+}
+
+// UnownExtraAnimation returns the idle animation for an Unown form.
+func (rip *Ripper) UnownExtraAnimation(form string) (*Animation, error) {
+	return rip.unownAnimation(form, "unown extra", rip.info.UnownExtraOffset)
 }
 
-func (rip *Ripper) UnownAnimation(form string) (g *gif.GIF, err error) {
+func (rip *Ripper) unownAnimation(form string, table string, base int64) (*Animation, error) {
 	if len(form) != 1 || 'a' > form[0] || form[0] > 'z' {
 		return nil, ErrNoSuchPokemon
 	}
@@ -524,44 +805,25 @@ func (rip *Ripper) UnownAnimation(form string) (g *gif.GIF, err error) {
 		return nil, err
 	}
 
-	rip.buf.Seek(readNearPointerAt(rip.r, rip.info.UnownAnimOffset, formi), 0)
-	animdata, err := rip.buf.ReadBytes('\xFF')
+	animdata, err := rip.readScript(table, base, formi)
 	if err != nil {
 		return nil, err
 	}
 
-	g = animate(animdata, frames)
-	return g, nil
-}
-
-func animate(animdata []byte, frames []*image.Paletted) *gif.GIF {
-	var g gif.GIF
-	var loop, clock int
-loop:
-	for pc := 0; ; pc += 2 {
-		//log.Println("PC", pc)
-		switch animdata[pc] {
-		case 0xFF:
-			break loop
-		case 0xFE:
-			loop = int(animdata[pc+1])
-		case 0xFD:
-			if loop > 0 {
-				pc = int(animdata[pc+1]) * 2
-				pc -= 2
-				loop--
-			}
-		default:
-			delay := int(animdata[pc+1])
-			g.Image = append(g.Image, frames[animdata[pc]])
-			//g.Delay = append(g.Delay, delay*100/60)
-			g.Delay = append(g.Delay, (clock+delay)*100/60-clock*100/60)
-			clock += delay
-		}
+	return animate(animdata, frames)
+}
+
+// ReadScript reads the animation script pointed to by entry n of a table.
+func (rip *Ripper) readScript(table string, base int64, n int) ([]byte, error) {
+	off, err := readNearPointerAt(rip.r, table, base, n)
+	if err != nil {
+		return nil, err
+	}
+	data, err := rip.readScriptAt(off)
+	if err != nil {
+		return nil, &TableError{table, n, off, err}
 	}
-	g.Image = append(g.Image, frames[0])
-	g.Delay = append(g.Delay, clock*2*100/60-clock)
-	return &g
+	return data, nil
 }
 
 func (rip *Ripper) PokemonFrames(number int) ([]*image.Paletted, error) {
@@ -571,38 +833,95 @@ func (rip *Ripper) PokemonFrames(number int) ([]*image.Paletted, error) {
 	return rip.pokemonFrames(number)
 }
 func (rip *Ripper) pokemonFrames(number int) ([]*image.Paletted, error) {
+	offsets, err := rip.pokemonAnimOffsets(number)
+	if err != nil {
+		return nil, err
+	}
+	w, h, err := rip.pokemonSize(number)
+	if err != nil {
+		return nil, err
+	}
+	palette, err := rip.pokemonPalette(number, normal)
+	if err != nil {
+		return nil, err
+	}
+	return rip.frames(offsets, palette, w, h)
+}
+
+func (rip *Ripper) pokemonAnimOffsets(number int) (animOffsets, error) {
 	// TODO: Kinda want to just slurp in all the animation data for
 	// every sprite at once. It's all in just a couple banks.
 	// OTOH, profiling shows that this isn't a bottleneck.
-	offsets := animOffsets{
-		Sprite:  rip.pokemonOffset(number, 0, front),
-		Anim:    readNearPointerAt(rip.r, rip.info.AnimOffset, number-1),
-		Extra:   readNearPointerAt(rip.r, rip.info.ExtraOffset, number-1),
-		Frames:  readNearPointerAt(rip.r, rip.info.FramesOffset, number-1),
-		Bitmaps: readNearPointerAt(rip.r, rip.info.BitmapsOffset, number-1),
+	offsets := animOffsets{Index: number - 1}
+	var err error
+	for _, p := range []struct {
+		off   *int64
+		table string
+		base  int64
+	}{
+		{&offsets.Anim, "anim", rip.info.AnimOffset},
+		{&offsets.Extra, "extra", rip.info.ExtraOffset},
+		{&offsets.Frames, "frames", rip.info.FramesOffset},
+		{&offsets.Bitmaps, "bitmaps", rip.info.BitmapsOffset},
+	} {
+		*p.off, err = readNearPointerAt(rip.r, p.table, p.base, number-1)
+		if err != nil {
+			return offsets, err
+		}
+	}
+	offsets.Sprite, err = rip.pokemonOffset(number, 0, front)
+	if err != nil {
+		return offsets, err
 	}
 	if number > 151 {
 		offsets.Frames += 0x4000
 	}
-	w, h := rip.pokemonSize(number)
-	palette := rip.pokemonPalette(number, normal)
-	return rip.frames(offsets, palette, w, h)
+	return offsets, nil
 }
 
 func (rip *Ripper) unownFrames(form int) ([]*image.Paletted, error) {
-	offsets := animOffsets{
-		Sprite:  rip.pokemonOffset(201, form, front),
-		Anim:    readNearPointerAt(rip.r, rip.info.UnownAnimOffset, form),
-		Extra:   readNearPointerAt(rip.r, rip.info.UnownExtraOffset, form),
-		Frames:  readNearPointerAt(rip.r, rip.info.UnownFramesOffset, form),
-		Bitmaps: readNearPointerAt(rip.r, rip.info.UnownBitmapsOffset, form),
-	}
-	w, h := rip.pokemonSize(201)
-	palette := rip.pokemonPalette(201, normal)
+	offsets, err := rip.unownAnimOffsets(form)
+	if err != nil {
+		return nil, err
+	}
+	w, h, err := rip.pokemonSize(201)
+	if err != nil {
+		return nil, err
+	}
+	palette, err := rip.pokemonPalette(201, normal)
+	if err != nil {
+		return nil, err
+	}
 	return rip.frames(offsets, palette, w, h)
 }
 
+func (rip *Ripper) unownAnimOffsets(form int) (animOffsets, error) {
+	offsets := animOffsets{Index: form, Unown: true}
+	var err error
+	for _, p := range []struct {
+		off   *int64
+		table string
+		base  int64
+	}{
+		{&offsets.Anim, "unown anim", rip.info.UnownAnimOffset},
+		{&offsets.Extra, "unown extra", rip.info.UnownExtraOffset},
+		{&offsets.Frames, "unown frames", rip.info.UnownFramesOffset},
+		{&offsets.Bitmaps, "unown bitmaps", rip.info.UnownBitmapsOffset},
+	} {
+		*p.off, err = readNearPointerAt(rip.r, p.table, p.base, form)
+		if err != nil {
+			return offsets, err
+		}
+	}
+	offsets.Sprite, err = rip.pokemonOffset(201, form, front)
+	return offsets, err
+}
+
+// AnimOffsets holds the location of the animation data for a pic.
+// Index is the entry number in the animation tables, for error reporting.
 type animOffsets struct {
+	Index   int
+	Unown   bool
 	Sprite  int64
 	Anim    int64
 	Extra   int64
@@ -610,72 +929,190 @@ type animOffsets struct {
 	Bitmaps int64
 }
 
-func (rip *Ripper) frames(offsets animOffsets, palette color.Palette, w, h int) ([]*image.Paletted, error) {
-	buf := rip.buf
+func (o *animOffsets) table(name string) string {
+	if o.Unown {
+		return "unown " + name
+	}
+	return name
+}
 
-	buf.Seek(offsets.Sprite, 0)
-	tiledata, err := decodeTiles(buf, w*h*8*2*2)
+func (rip *Ripper) frames(offsets animOffsets, palette color.Palette, w, h int) ([]*image.Paletted, error) {
+	tiledata, err := decodeTiles(rip.at(offsets.Sprite), w*h*8*2*2)
+	if err != nil {
+		return nil, &TableError{offsets.table("sprite"), offsets.Index * 2, offsets.Sprite, err}
+	}
+	d, err := rip.readAnimData(offsets, w, h)
 	if err != nil {
 		return nil, err
 	}
+	frames, bad, err := d.compose(tiledata, palette, w, h)
+	if err != nil {
+		if bad < 0 {
+			return nil, &TableError{offsets.table("sprite"), offsets.Index * 2, offsets.Sprite, err}
+		}
+		return nil, &TableError{offsets.table("frame"), bad, d.frameOffsets[bad], err}
+	}
+	return frames, nil
+}
+
+// AnimData holds the raw animation data for a pic, as stored in the ROM.
+type AnimData struct {
+	Anim    []byte   // animation script
+	Extra   []byte   // idle animation script
+	Bitmaps [][]byte // tile masks, one bit per tile of the pic
+	Frames  [][]byte // a bitmap number, followed by a tile for each set bit
+
+	frameOffsets []int64 // ROM offset of each frame, for error reporting
+}
 
-	buf.Seek(offsets.Anim, 0)
-	animdata, err := buf.ReadBytes('\xFF')
+func (rip *Ripper) PokemonAnimData(number int) (*AnimData, error) {
+	if 1 > number || number > MaxPokemon {
+		return nil, ErrNoSuchPokemon
+	}
+	offsets, err := rip.pokemonAnimOffsets(number)
 	if err != nil {
 		return nil, err
 	}
-	//fmt.Fprintf(os.Stderr, "%x\n", animdata)
+	w, h, err := rip.pokemonSize(number)
+	if err != nil {
+		return nil, err
+	}
+	return rip.readAnimData(offsets, w, h)
+}
 
-	buf.Seek(offsets.Extra, 0)
-	extradata, err := buf.ReadBytes('\xFF')
+func (rip *Ripper) UnownAnimData(form string) (*AnimData, error) {
+	if len(form) != 1 || 'a' > form[0] || form[0] > 'z' {
+		return nil, ErrNoSuchPokemon
+	}
+	offsets, err := rip.unownAnimOffsets(int(form[0] - 'a'))
 	if err != nil {
 		return nil, err
 	}
+	w, h, err := rip.pokemonSize(201)
+	if err != nil {
+		return nil, err
+	}
+	return rip.readAnimData(offsets, w, h)
+}
 
-	// Find the number of frames by picking the highest frame in the anim data.
-	var nframes int
-	for i := 0; i < len(animdata); i += 2 {
-		if animdata[i] < 0x80 && nframes < int(animdata[i]) {
-			nframes = int(animdata[i])
-		}
+func (rip *Ripper) readAnimData(offsets animOffsets, w, h int) (*AnimData, error) {
+	var d AnimData
+	var err error
+	d.Anim, err = rip.readScriptAt(offsets.Anim)
+	if err != nil {
+		return nil, &TableError{offsets.table("anim"), offsets.Index, offsets.Anim, err}
 	}
-	for i := 0; i < len(extradata); i += 2 {
-		if extradata[i] < 0x80 && nframes < int(extradata[i]) {
-			nframes = int(extradata[i])
-		}
+	//fmt.Fprintf(os.Stderr, "%x\n", d.Anim)
+
+	d.Extra, err = rip.readScriptAt(offsets.Extra)
+	if err != nil {
+		return nil, &TableError{offsets.table("extra"), offsets.Index, offsets.Extra, err}
 	}
+
+	nframes := d.numFrames()
 	//fmt.Fprintf(os.Stderr, "%d frames\n", nframes)
 
+	// Frames can share bitmaps, so there are usually fewer bitmaps than
+	// frames. Find out how many there are from the frames that use them
+	// before reading them.
+	offs := make([]int64, nframes)
+	nbitmaps := 0
+	for i := range offs {
+		off, err := readNearPointerAt(rip.r, offsets.table("frame"), offsets.Frames, i)
+		if err != nil {
+			return nil, err
+		}
+		var b [1]byte
+		_, err = rip.r.ReadAt(b[:], off)
+		if err != nil {
+			return nil, &TableError{offsets.table("frame"), i, off, noEOF(err)}
+		}
+		//fmt.Fprintf(os.Stderr, "bitmap %d\n", b[0])
+		if int(b[0]) >= nbitmaps {
+			nbitmaps = int(b[0]) + 1
+		}
+		offs[i] = off
+	}
+
 	bitmaplen := (w*h + 7) / 8 // 1 pixel per tile
-	bitmapdata := make([]byte, bitmaplen*nframes)
+	bitmapdata := make([]byte, bitmaplen*nbitmaps)
 	_, err = rip.r.ReadAt(bitmapdata, offsets.Bitmaps)
 	if err != nil {
-		return nil, err
+		return nil, &TableError{offsets.table("bitmaps"), offsets.Index, offsets.Bitmaps, noEOF(err)}
+	}
+	for i := 0; i < nbitmaps; i++ {
+		d.Bitmaps = append(d.Bitmaps, bitmapdata[i*bitmaplen:(i+1)*bitmaplen])
+	}
+
+	for i, off := range offs {
+		bn := rip.rom[off]
+		frame := make([]byte, 1+countTiles(d.Bitmaps[bn], w*h))
+		frame[0] = bn
+		_, err = rip.r.ReadAt(frame[1:], off+1)
+		if err != nil {
+			return nil, &TableError{offsets.table("frame"), i, off, noEOF(err)}
+		}
+		d.Frames = append(d.Frames, frame)
+		d.frameOffsets = append(d.frameOffsets, off)
+	}
+	return &d, nil
+}
+
+// NumFrames returns the number of frames used by the animation scripts,
+// not counting frame 0.
+func (d *AnimData) numFrames() int {
+	var nframes int
+	for _, script := range [][]byte{d.Anim, d.Extra} {
+		for i := 0; i < len(script); i += 2 {
+			if script[i] < 0x80 && nframes < int(script[i]) {
+				nframes = int(script[i])
+			}
+		}
+	}
+	return nframes
+}
+
+// CountTiles returns the number of tiles selected by the first n bits of
+// a bitmap.
+func countTiles(bitmap []byte, n int) int {
+	var count int
+	for i := 0; i < n && i/8 < len(bitmap); i++ {
+		count += int(bitmap[i/8] >> uint(i%8) & 1)
 	}
+	return count
+}
 
-	var frames = make([]*image.Paletted, nframes+1)
+// Compose builds the frames of an animation from the pic's tiles,
+// which are followed by the extra tiles that the frames refer to.
+// If a frame is malformed, compose also returns its index; if the tile data
+// is too short, it returns -1.
+func (d *AnimData) compose(tiledata []byte, palette color.Palette, w, h int) (frames []*image.Paletted, bad int, err error) {
 	var data = make([]uint8, w*h*8*2)
+	if len(tiledata) < len(data) {
+		return nil, -1, ErrTooSmall
+	}
+	bitmaplen := (w*h + 7) / 8
+	frames = make([]*image.Paletted, len(d.Frames)+1)
 	var m = image.NewPaletted(image.Rect(0, 0, w*8, h*8), palette)
 	untile(m, tiledata)
 	frames[0] = m
-	for i := 0; i < nframes; i++ {
-		buf.Seek(readNearPointerAt(rip.r, offsets.Frames, i), 0)
-		bn, _ := buf.ReadByte()
-		//fmt.Fprintf(os.Stderr, "bitmap %d\n", bn)
-		if int(bn) > nframes {
-			return nil, ErrMalformed
+	for i, frame := range d.Frames {
+		if len(frame) == 0 || int(frame[0]) >= len(d.Bitmaps) || len(d.Bitmaps[frame[0]]) < bitmaplen {
+			return nil, i, ErrMalformed
 		}
-		bitindex := uint(bn) * uint(bitmaplen) * 8
-		for di := 0; di < len(data); di += 16 {
-			bit := bitmapdata[bitindex/8] >> (bitindex % 8) & 1
-			bitindex++
+		bitmap := d.Bitmaps[frame[0]]
+		tiles := frame[1:]
+		for t, di := 0, 0; di < len(data); t, di = t+1, di+16 {
 			si := di
-			if bit != 0 {
-				b, _ := buf.ReadByte()
-				si = int(b) * 16
+			if bitmap[t/8]>>uint(t%8)&1 != 0 {
+				if len(tiles) == 0 {
+					return nil, i, ErrMalformed
+				}
+				si = int(tiles[0]) * 16
+				tiles = tiles[1:]
 			}
 			if si+16 > len(tiledata) {
-				return nil, ErrMalformed
+				return nil, i, ErrMalformed
 			}
 			copy(data[di:di+16], tiledata[si:si+16])
 		}
@@ -683,7 +1120,7 @@ func (rip *Ripper) frames(offsets animOffsets, palette color.Palette, w, h int)
 		untile(m, data)
 		frames[i+1] = m
 	}
-	return frames, nil
+	return frames, -1, nil
 }
 
 const (
@@ -691,20 +1128,32 @@ const (
 	back
 )
 
-func (rip *Ripper) pokemonOffset(number int, form int, facing int) (off int64) {
-	base := rip.info.SpriteOffset
-	n := number - 1
+// PokemonPointer returns the name and offset of the pointer table for a
+// Pokémon's pic, and the index of the pic in the table.
+func (rip *Ripper) pokemonPointer(number int, form int, facing int) (table string, base int64, n int) {
 	if number == 201 {
-		base = rip.info.UnownSpriteOffset
-		n = form
+		return "unown sprite", rip.info.UnownSpriteOffset, 2*form + facing
 	}
-	off = rip.farPointer(base, 2*n+facing)
-	return off
+	return "sprite", rip.info.SpriteOffset, 2*(number-1) + facing
 }
 
-func (rip *Ripper) farPointer(base int64, n int) int64 {
-	off := readFarPointerAt(rip.r, base, n)
-	if rip.info.Title == "PM_CRYSTAL" {
+func (rip *Ripper) pokemonOffset(number int, form int, facing int) (off int64, err error) {
+	table, base, n := rip.pokemonPointer(number, form, facing)
+	return rip.farPointer(table, base, n)
+}
+
+func (rip *Ripper) farPointer(table string, base int64, n int) (int64, error) {
+	off, err := readFarPointerAt(rip.r, table, base, n)
+	if err != nil {
+		return 0, err
+	}
+	return fixFarPointer(off, rip.info.Title == "PM_CRYSTAL"), nil
+}
+
+// FixFarPointer maps the bank number of a pic pointer to the bank the pic
+// is actually in.
+func fixFarPointer(off int64, crystal bool) int64 {
+	if crystal {
 		off += 0x36 << 14
 	} else {
 		switch off >> 14 {
@@ -717,26 +1166,36 @@ func (rip *Ripper) farPointer(base int64, n int) int64 {
 	return off
 }
 
-func readFarPointerAt(r io.ReaderAt, off int64, n int) int64 {
+func readFarPointerAt(r io.ReaderAt, table string, off int64, n int) (int64, error) {
 	var b [3]byte
 	off += int64(len(b)) * int64(n)
 	_, err := r.ReadAt(b[:], off)
 	if err != nil {
-		// BUG: shouldn't panic
-		panic(err)
+		return 0, &TableError{table, n, off, noEOF(err)}
 	}
+	return readFarPointer(b[:]), nil
+}
+
+func readFarPointer(b []byte) int64 {
 	bank := int64(b[0])
 	return bank<<14 + int64(b[2])&0x3F<<8 + int64(b[1])
 }
 
-func readNearPointerAt(r io.ReaderAt, off int64, n int) int64 {
+func readNearPointerAt(r io.ReaderAt, table string, off int64, n int) (int64, error) {
 	var b [2]byte
 	off += int64(len(b)) * int64(n)
 	_, err := r.ReadAt(b[:], off)
 	if err != nil {
-		// BUG: shouldn't panic
-		panic(err)
+		return 0, &TableError{table, n, off, noEOF(err)}
 	}
 	p := off&^0x3FFF + int64(b[1])&0x3F<<8 + int64(b[0])
-	return p
+	return p, nil
+}
+
+// NoEOF converts io.EOF to io.ErrUnexpectedEOF.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
 }
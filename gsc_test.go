@@ -1,7 +1,16 @@
 package sprites
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
 	"os"
+	"reflect"
+	"sync"
 	"testing"
 )
 
@@ -47,3 +56,278 @@ func BenchmarkAnimations(B *testing.B) {
 		}
 	}
 }
+
+func TestAnimateMalformed(t *testing.T) {
+	frames := make([]*image.Paletted, 2)
+	for i := range frames {
+		frames[i] = image.NewPaletted(image.Rect(0, 0, 8, 8), defaultPalette)
+	}
+	for _, script := range [][]byte{
+		{0x02, 0x10, 0xFF},             // no such frame
+		{0x01, 0x10},                   // no end
+		{0x01, 0x10, 0xFD},             // truncated command
+		{0xFE, 0x01, 0xFD, 0x05, 0xFF}, // jump past the end
+		{0xFE, 0x01, 0xFD, 0x00, 0xFF}, // loops forever
+	} {
+		_, err := animate(script, frames)
+		if err != ErrMalformed {
+			t.Errorf("animate(%x): got %v, want %v", script, err, ErrMalformed)
+		}
+	}
+	a, err := animate([]byte{0x01, 0x10, 0xFE, 0x01, 0x00, 0x08, 0xFD, 0x02, 0xFF}, frames)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g := a.GIF(); len(g.Image) != 4 {
+		t.Errorf("got %d frames, want 4", len(g.Image))
+	}
+}
+
+func TestAnimate(t *testing.T) {
+	frames := make([]*image.Paletted, 3)
+	for i := range frames {
+		frames[i] = image.NewPaletted(image.Rect(0, 0, 8, 8), defaultPalette)
+	}
+	script := []byte{
+		0x01, 0x10, // frame 1, 16
+		0xFE, 0x02, // setrepeat 2
+		0x02, 0x08, // frame 2, 8
+		0x00, 0x04, // frame 0, 4
+		0xFD, 0x02, // dorepeat 2
+		0x01, 0x20, // frame 1, 32
+		0xFD, 0x00, // dorepeat 0 (counter is spent)
+		0xFF,
+	}
+	a, err := animate(script, frames)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantSteps := []AnimStep{{1, 16}, {2, 8}, {0, 4}, {1, 32}}
+	wantLoops := []AnimLoop{{1, 3, 2}}
+	if !reflect.DeepEqual(a.Steps, wantSteps) {
+		t.Errorf("steps: got %v, want %v", a.Steps, wantSteps)
+	}
+	if !reflect.DeepEqual(a.Loops, wantLoops) {
+		t.Errorf("loops: got %v, want %v", a.Loops, wantLoops)
+	}
+	if d := a.Duration(); d != 16+3*(8+4)+32 {
+		t.Errorf("got duration %d, want %d", d, 16+3*(8+4)+32)
+	}
+	b, err := json.Marshal(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"frames":3,"steps":[{"frame":1,"duration":16},{"frame":2,"duration":8},{"frame":0,"duration":4},{"frame":1,"duration":32}],"loops":[{"start":1,"end":3,"count":2}]}`
+	if string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+}
+
+func TestDVs(t *testing.T) {
+	for _, tt := range []struct {
+		atk, def, spd, spc int
+		shiny              bool
+		form               string
+	}{
+		{0, 0, 0, 0, false, "a"},
+		{15, 15, 15, 15, false, "z"},
+		// Shiny Unown are always I or V.
+		{2, 10, 10, 10, true, "i"},
+		{15, 10, 10, 10, true, "v"},
+		{1, 10, 10, 10, false, "c"},
+		{14, 10, 10, 11, false, "v"},
+		{2, 10, 10, 11, false, "i"},
+	} {
+		if got := IsShiny(tt.atk, tt.def, tt.spd, tt.spc); got != tt.shiny {
+			t.Errorf("IsShiny(%d, %d, %d, %d) = %v, want %v", tt.atk, tt.def, tt.spd, tt.spc, got, tt.shiny)
+		}
+		if got := UnownForm(tt.atk, tt.def, tt.spd, tt.spc); got != tt.form {
+			t.Errorf("UnownForm(%d, %d, %d, %d) = %q, want %q", tt.atk, tt.def, tt.spd, tt.spc, got, tt.form)
+		}
+	}
+}
+
+func TestPokemonPaletteFor(t *testing.T) {
+	rom := make([]byte, 0x4000)
+	// Pikachu's normal palette.
+	copy(rom[0x1000+25*8:], []byte{0x1F, 0x00, 0x00, 0x7C})
+	rip := newTestRipper(rom, RomInfo{PaletteOffset: 0x1000})
+	want := color.Palette{color.White, RGB15(0x001F), RGB15(0x7C00), color.Black}
+
+	pal, err := rip.PokemonPaletteFor(25, "gbc")
+	if err != nil || !reflect.DeepEqual(pal, want) {
+		t.Errorf("gbc: got %v, %v; want %v", pal, err, want)
+	}
+	if _, err := rip.PokemonPaletteFor(25, "sgb"); err != ErrNoSuchSystem {
+		t.Errorf("sgb without SGB support: got error %v, want %v", err, ErrNoSuchSystem)
+	}
+	rom[0x146] = 3
+	pal, err = rip.PokemonPaletteFor(25, "sgb")
+	if err != nil || !reflect.DeepEqual(pal, want) {
+		t.Errorf("sgb: got %v, %v; want %v", pal, err, want)
+	}
+	pal, err = rip.PokemonPaletteFor(25, "gb")
+	if err != nil || !reflect.DeepEqual(pal, color.Palette{color.Gray{255}, color.Gray{170}, color.Gray{85}, color.Gray{0}}) {
+		t.Errorf("gb: got %v, %v; want 255, 170, 85, and 0", pal, err)
+	}
+	rom[0x143] = 0xC0 // GBC only
+	if _, err := rip.PokemonPaletteFor(25, "gb"); err != ErrNoSuchSystem {
+		t.Errorf("gb on a GBC-only game: got error %v, want %v", err, ErrNoSuchSystem)
+	}
+	if _, err := rip.PokemonPaletteFor(25, "nes"); err != ErrNoSuchSystem {
+		t.Errorf("nes: got error %v, want %v", err, ErrNoSuchSystem)
+	}
+}
+
+func TestReadAnimData(t *testing.T) {
+	// Three frames share two bitmasks. The bitmasks of the next Pokémon
+	// follow directly.
+	rom := make([]byte, 0x8000)
+	copy(rom[0x4100:], []byte{0x01, 0x08, 0x02, 0x08, 0x03, 0x08, 0xFF}) // anim
+	rom[0x4110] = 0xFF                                                   // extra
+	copy(rom[0x4200:], []byte{0x00, 0x43, 0x10, 0x43, 0x20, 0x43})       // frame pointers
+	copy(rom[0x4300:], []byte{0x00, 0x19, 0x1A})
+	copy(rom[0x4310:], []byte{0x01, 0x19, 0x1A, 0x19})
+	copy(rom[0x4320:], []byte{0x00, 0x1B, 0x1C})
+	copy(rom[0x4400:], []byte{
+		0x01, 0x00, 0x00, 0x01,
+		0xC0, 0x01, 0x00, 0x00,
+		0xFF, 0xFF, 0xFF, 0xFF,
+	})
+	rip := newTestRipper(rom, RomInfo{})
+	d, err := rip.readAnimData(animOffsets{Anim: 0x4100, Extra: 0x4110, Frames: 0x4200, Bitmaps: 0x4400}, 5, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Bitmaps) != 2 || len(d.Frames) != 3 {
+		t.Fatalf("got %d bitmasks and %d frames, want 2 and 3", len(d.Bitmaps), len(d.Frames))
+	}
+
+	// The files are laid out as in the pokecrystal disassembly, e.g.
+	// gfx/pokemon/<name>/bitmask.asm and frames.asm.
+	var buf bytes.Buffer
+	if err := WriteBitmasks(&buf, d.Bitmaps); err != nil {
+		t.Fatal(err)
+	}
+	want := "; 0\n" +
+		"\tdb %00000001\n\tdb %00000000\n\tdb %00000000\n\tdb %00000001\n" +
+		"; 1\n" +
+		"\tdb %11000000\n\tdb %00000001\n\tdb %00000000\n\tdb %00000000\n"
+	if buf.String() != want {
+		t.Errorf("bitmask.asm: got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+	buf.Reset()
+	if err := WriteFrames(&buf, d.Frames); err != nil {
+		t.Fatal(err)
+	}
+	want = "\tdw .frame1\n\tdw .frame2\n\tdw .frame3\n" +
+		".frame1\n\tdb $00 ; bitmask\n\tdb $19, $1a\n" +
+		".frame2\n\tdb $01 ; bitmask\n\tdb $19, $1a, $19\n" +
+		".frame3\n\tdb $00 ; bitmask\n\tdb $1b, $1c\n"
+	if buf.String() != want {
+		t.Errorf("frames.asm: got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		size   int
+		info   RomInfo
+		rip    func(rip *Ripper) error
+		table  string
+		index  int
+		offset int64
+	}{
+		{
+			"stats", 0x100 + 24*statsSize + 8,
+			RomInfo{StatsOffset: 0x100},
+			func(rip *Ripper) error { _, err := rip.Pokemon(25); return err },
+			"stats", 24, 0x100 + 24*statsSize,
+		},
+		{
+			"sprite", 0x1000 + 48*3 + 1,
+			RomInfo{StatsOffset: 0x100, SpriteOffset: 0x1000},
+			func(rip *Ripper) error { _, err := rip.Pokemon(25); return err },
+			"sprite", 48, 0x1000 + 48*3,
+		},
+		{
+			"trainer", 0x1000 + 2*3 + 2,
+			RomInfo{TrainerOffset: 0x1000},
+			func(rip *Ripper) error { _, err := rip.Trainer(3); return err },
+			"trainer", 2, 0x1000 + 2*3,
+		},
+		{
+			"unown sprite", 0x2000 + 4*3 + 1,
+			RomInfo{StatsOffset: 0x100, UnownSpriteOffset: 0x2000},
+			func(rip *Ripper) error { _, err := rip.Unown("c"); return err },
+			"unown sprite", 4, 0x2000 + 4*3,
+		},
+		{
+			"anim", 0x1000 + 24*2 + 1,
+			RomInfo{StatsOffset: 0x100, AnimOffset: 0x1000},
+			func(rip *Ripper) error { _, err := rip.PokemonAnimation(25); return err },
+			"anim", 24, 0x1000 + 24*2,
+		},
+	} {
+		rip := newTestRipper(make([]byte, tt.size), tt.info)
+		err := tt.rip(rip)
+		var te *TableError
+		if !errors.As(err, &te) {
+			t.Errorf("%s: got %v, want a *TableError", tt.name, err)
+			continue
+		}
+		if te.Table != tt.table || te.Index != tt.index || te.Offset != tt.offset {
+			t.Errorf("%s: got table %q, index %d, offset %#x; want %q, %d, %#x",
+				tt.name, te.Table, te.Index, te.Offset, tt.table, tt.index, tt.offset)
+		}
+		if te.Err != io.ErrUnexpectedEOF {
+			t.Errorf("%s: got %v, want %v", tt.name, te.Err, io.ErrUnexpectedEOF)
+		}
+	}
+}
+
+func TestConcurrent(t *testing.T) {
+	rom, info := newScanRom()
+	// Every Pokémon has the same empty animation.
+	info.AnimOffset = 0x2C000
+	info.ExtraOffset = 0x2C200
+	info.FramesOffset = 0x2C400
+	info.BitmapsOffset = 0x2C600
+	for i := 0; i < MaxPokemon; i++ {
+		for _, table := range []int64{info.AnimOffset, info.ExtraOffset, info.FramesOffset, info.BitmapsOffset} {
+			rom[table+int64(i)*2], rom[table+int64(i)*2+1] = 0x00, 0x48
+		}
+	}
+	rom[0x2C800] = 0xFF
+	rip := newTestRipper(rom, info)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for g := 0; g < cap(errs); g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for n := 1 + g; n <= MaxPokemon; n += cap(errs) {
+				if _, err := rip.Pokemon(n); err != nil {
+					errs <- fmt.Errorf("pokemon %d: %v", n, err)
+					return
+				}
+				if _, err := rip.PokemonAnimation(n); err != nil {
+					errs <- fmt.Errorf("pokemon %d animation: %v", n, err)
+					return
+				}
+				form := UnownForms[n%len(UnownForms)]
+				if _, err := rip.Unown(form); err != nil {
+					errs <- fmt.Errorf("unown %s: %v", form, err)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
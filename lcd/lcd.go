@@ -0,0 +1,120 @@
+// Package lcd approximates the colors of handheld LCD screens.
+//
+// Palettes ripped from a game are exactly the colors the game asked for,
+// but the screens it was played on showed them darker and less saturated.
+// Emulators correct for this in various ways; each Profile here follows one
+// of them.
+package lcd
+
+import (
+	"errors"
+	"image/color"
+)
+
+var ErrNoSuchProfile = errors.New("no such color correction profile")
+
+// A Profile maps a color to the color a screen would show for it.
+// Alpha is left alone.
+type Profile func(c color.NRGBA) color.NRGBA
+
+// Palette returns a corrected copy of p.
+func (f Profile) Palette(p color.Palette) color.Palette {
+	q := make(color.Palette, len(p))
+	for i, c := range p {
+		q[i] = f(color.NRGBAModel.Convert(c).(color.NRGBA))
+	}
+	return q
+}
+
+var profiles = []struct {
+	name string
+	f    Profile
+}{
+	{"none", none},
+	{"vba", vba},
+	{"gnuboy", gnuboy},
+	{"gambatte", gambatte},
+	{"washout", washout},
+}
+
+// Names returns the names of the profiles.
+func Names() []string {
+	var names []string
+	for _, p := range profiles {
+		names = append(names, p.name)
+	}
+	return names
+}
+
+// Lookup returns the named profile.
+func Lookup(name string) (Profile, error) {
+	for _, p := range profiles {
+		if p.name == name {
+			return p.f, nil
+		}
+	}
+	return nil, ErrNoSuchProfile
+}
+
+// None leaves colors as they are.
+func none(c color.NRGBA) color.NRGBA {
+	return c
+}
+
+// Vba is the GBC color filter from VisualBoyAdvance. It lifts the black
+// level and bleeds each channel into the others, more so for bright colors.
+// http://sourceforge.net/p/vbam/code/1226/tree/trunk/src/gb/GB.cpp#l585
+func vba(c color.NRGBA) color.NRGBA {
+	r := getValue(
+		getValue(33, 115, c.G),
+		getValue(198, 239, c.G),
+		c.R) - 33
+	r41 := getValue(0, 41, c.R)
+	r25 := getValue(0, 25, c.R)
+	r8 := getValue(0, 8, c.R)
+	g := getValue(
+		getValue(33+r41, 115+r25, c.B),
+		getValue(198+r25, 229+r8, c.B),
+		c.G) - 33
+	b := getValue(
+		getValue(33+r41, 115+r25, c.G),
+		getValue(198+r25, 229+r8, c.G),
+		c.B) - 33
+	return color.NRGBA{uint8(r), uint8(g), uint8(b), c.A}
+}
+
+// GetValue interpolates between min and max along a curve which rises
+// quickly and then levels off.
+func getValue(min, max int, v uint8) int {
+	u := float32(v) / 255
+	return min + int(float32(max-min)*(2*u-u*u))
+}
+
+// Gnuboy is the color filter from gnuboy: a fixed mix of the channels,
+// plus an offset.
+// https://code.google.com/p/gnuboy/source/browse/trunk/lcd.c?r=199#722
+func gnuboy(c color.NRGBA) color.NRGBA {
+	r, g, b := uint16(c.R), uint16(c.G), uint16(c.B)
+	rr := (r*195+g*25+b*0)>>8 + 35
+	gg := (r*25+g*170+b*25)>>8 + 35
+	bb := (r*25+g*60+b*125)>>8 + 40
+	return color.NRGBA{uint8(rr), uint8(gg), uint8(bb), c.A}
+}
+
+// Gambatte is the GBC color filter from Gambatte. It works on the
+// original 5-bit channels.
+func gambatte(c color.NRGBA) color.NRGBA {
+	r, g, b := uint16(c.R)>>3, uint16(c.G)>>3, uint16(c.B)>>3
+	rr := (r*13 + g*2 + b) / 2
+	gg := (r*0 + g*12 + b*4) / 2
+	bb := (r*3 + g*2 + b*11) / 2
+	return color.NRGBA{uint8(rr), uint8(gg), uint8(bb), c.A}
+}
+
+// Washout is the last of the experimental filters that rby-sprite-extract
+// used to carry, muteColors4. It doesn't model any particular screen or
+// emulator: it halves each channel and adds 82, pulling every color toward
+// a light gray.
+func washout(c color.NRGBA) color.NRGBA {
+	return color.NRGBA{c.R/2 + 82, c.G/2 + 82, c.B/2 + 82, c.A}
+}
@@ -0,0 +1,78 @@
+package lcd
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestProfiles(t *testing.T) {
+	p := color.Palette{
+		color.NRGBA{255, 255, 255, 255},
+		color.NRGBA{255, 0, 0, 255},
+		color.NRGBA{0, 0, 0, 0},
+	}
+	for _, name := range Names() {
+		f, err := Lookup(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		q := f.Palette(p)
+		if len(q) != len(p) {
+			t.Fatalf("%s: got %d colors, want %d", name, len(q), len(p))
+		}
+		for i := range q {
+			_, _, _, a := q[i].RGBA()
+			_, _, _, want := p[i].RGBA()
+			if a != want {
+				t.Errorf("%s: color %d has alpha %x, want %x", name, i, a, want)
+			}
+		}
+		if name == "none" {
+			if q[1] != p[1] {
+				t.Errorf("none: got %v, want %v", q[1], p[1])
+			}
+		} else if q[1] == p[1] {
+			t.Errorf("%s: red was left unchanged", name)
+		}
+	}
+	if p[1] != (color.NRGBA{255, 0, 0, 255}) {
+		t.Error("the original palette was modified")
+	}
+	if _, err := Lookup("crt"); err != ErrNoSuchProfile {
+		t.Errorf("got %v, want ErrNoSuchProfile", err)
+	}
+}
+
+func TestGambatte(t *testing.T) {
+	got := gambatte(color.NRGBA{255, 255, 255, 255})
+	want := color.NRGBA{248, 248, 248, 255}
+	if got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestKnownValues(t *testing.T) {
+	white := color.NRGBA{255, 255, 255, 255}
+	red := color.NRGBA{255, 0, 0, 255}
+	for _, tt := range []struct {
+		name       string
+		white, red color.NRGBA
+	}{
+		{"none", white, red},
+		{"vba", color.NRGBA{206, 204, 204, 255}, color.NRGBA{165, 41, 41, 255}},
+		{"gnuboy", color.NRGBA{254, 254, 249, 255}, color.NRGBA{229, 59, 64, 255}},
+		{"gambatte", color.NRGBA{248, 248, 248, 255}, color.NRGBA{201, 0, 46, 255}},
+		{"washout", color.NRGBA{209, 209, 209, 255}, color.NRGBA{209, 82, 82, 255}},
+	} {
+		f, err := Lookup(tt.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := f(white); got != tt.white {
+			t.Errorf("%s: white: got %v, want %v", tt.name, got, tt.white)
+		}
+		if got := f(red); got != tt.red {
+			t.Errorf("%s: red: got %v, want %v", tt.name, got, tt.red)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+// Package montage lays out pics in a grid, as a contact sheet.
+package montage
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// Align is the position of a pic within its cell, along one axis.
+type Align int
+
+const (
+	Center Align = iota
+	Start        // left or top
+	End          // right or bottom
+)
+
+// Options control the layout of a montage. The zero value is a square-ish
+// grid of cells just big enough for the largest pic, with the pics centered
+// on a transparent background.
+type Options struct {
+	Columns    int // the number of cells in each row
+	CellWidth  int // the size of each cell; larger pics are cropped
+	CellHeight int
+	Padding    int         // the space between cells and around the edge
+	Background color.Color // nil for transparent
+
+	HAlign Align
+	VAlign Align
+}
+
+const maxColors = 256
+
+// Draw lays out the pics in rows, left to right. A nil pic leaves its cell
+// empty.
+//
+// If the pics use no more than 256 colors between them, counting the
+// background, the montage is an *image.Paletted whose first color is the
+// background. Otherwise it is an *image.NRGBA.
+func Draw(pics []image.Image, opt *Options) image.Image {
+	var o Options
+	if opt != nil {
+		o = *opt
+	}
+	if o.Background == nil {
+		o.Background = color.Transparent
+	}
+	if o.Columns <= 0 {
+		o.Columns = int(math.Ceil(math.Sqrt(float64(len(pics)))))
+		if o.Columns == 0 {
+			o.Columns = 1
+		}
+	}
+	if o.CellWidth <= 0 || o.CellHeight <= 0 {
+		var w, h int
+		for _, m := range pics {
+			if m == nil {
+				continue
+			}
+			size := m.Bounds().Size()
+			if size.X > w {
+				w = size.X
+			}
+			if size.Y > h {
+				h = size.Y
+			}
+		}
+		if o.CellWidth <= 0 {
+			o.CellWidth = w
+		}
+		if o.CellHeight <= 0 {
+			o.CellHeight = h
+		}
+	}
+
+	rows := (len(pics) + o.Columns - 1) / o.Columns
+	b := image.Rect(0, 0,
+		o.Columns*(o.CellWidth+o.Padding)+o.Padding,
+		rows*(o.CellHeight+o.Padding)+o.Padding)
+
+	var set func(x, y int, c color.Color)
+	var dst image.Image
+	if pal := combinedPalette(pics, o.Background); pal != nil {
+		m := image.NewPaletted(b, pal)
+		index := make(map[color.RGBA64]uint8, len(pal))
+		for i, c := range pal {
+			index[key(c)] = uint8(i)
+		}
+		set = func(x, y int, c color.Color) {
+			m.Pix[m.PixOffset(x, y)] = index[key(c)]
+		}
+		dst = m
+	} else {
+		m := image.NewNRGBA(b)
+		bg := color.NRGBAModel.Convert(o.Background).(color.NRGBA)
+		for i := 0; i < len(m.Pix); i += 4 {
+			m.Pix[i+0] = bg.R
+			m.Pix[i+1] = bg.G
+			m.Pix[i+2] = bg.B
+			m.Pix[i+3] = bg.A
+		}
+		set = func(x, y int, c color.Color) {
+			m.Set(x, y, c)
+		}
+		dst = m
+	}
+
+	for i, m := range pics {
+		if m == nil {
+			continue
+		}
+		cell := image.Rect(0, 0, o.CellWidth, o.CellHeight).Add(image.Pt(
+			o.Padding+i%o.Columns*(o.CellWidth+o.Padding),
+			o.Padding+i/o.Columns*(o.CellHeight+o.Padding)))
+		sb := m.Bounds()
+		offset := cell.Min.Add(image.Pt(
+			align(o.HAlign, o.CellWidth-sb.Dx()),
+			align(o.VAlign, o.CellHeight-sb.Dy()))).Sub(sb.Min)
+		r := sb.Add(offset).Intersect(cell)
+		// Transparent pixels let the background show through.
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				c := m.At(x-offset.X, y-offset.Y)
+				if _, _, _, a := c.RGBA(); a != 0 {
+					set(x, y, c)
+				}
+			}
+		}
+	}
+	return dst
+}
+
+// Align returns the offset of a pic in its cell, given the space left over.
+func align(a Align, free int) int {
+	switch a {
+	case Start:
+		return 0
+	case End:
+		return free
+	default:
+		return free / 2
+	}
+}
+
+// CombinedPalette returns the background and every color used by the pics,
+// or nil if there are too many.
+func combinedPalette(pics []image.Image, bg color.Color) color.Palette {
+	pal := color.Palette{bg}
+	seen := map[color.RGBA64]bool{key(bg): true}
+	add := func(c color.Color) bool {
+		if _, _, _, a := c.RGBA(); a == 0 {
+			return true // drawn as the background
+		}
+		k := key(c)
+		if seen[k] {
+			return true
+		}
+		if len(pal) == maxColors {
+			return false
+		}
+		seen[k] = true
+		pal = append(pal, c)
+		return true
+	}
+	for _, m := range pics {
+		switch m := m.(type) {
+		case nil:
+		case *image.Paletted:
+			var used [maxColors]bool
+			for y := m.Rect.Min.Y; y < m.Rect.Max.Y; y++ {
+				for _, i := range m.Pix[m.PixOffset(m.Rect.Min.X, y):m.PixOffset(m.Rect.Max.X, y)] {
+					used[i] = true
+				}
+			}
+			for i, c := range m.Palette {
+				if used[i] && !add(c) {
+					return nil
+				}
+			}
+		default:
+			b := m.Bounds()
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				for x := b.Min.X; x < b.Max.X; x++ {
+					if !add(m.At(x, y)) {
+						return nil
+					}
+				}
+			}
+		}
+	}
+	return pal
+}
+
+// Key returns a comparable form of c.
+func key(c color.Color) color.RGBA64 {
+	return color.RGBA64Model.Convert(c).(color.RGBA64)
+}
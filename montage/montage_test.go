@@ -0,0 +1,89 @@
+package montage
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+var (
+	red   = color.NRGBA{255, 0, 0, 255}
+	blue  = color.NRGBA{0, 0, 255, 255}
+	white = color.NRGBA{255, 255, 255, 255}
+)
+
+func solid(w, h int, c color.Color) *image.Paletted {
+	m := image.NewPaletted(image.Rect(0, 0, w, h), color.Palette{color.Transparent, c})
+	for i := range m.Pix {
+		m.Pix[i] = 1
+	}
+	return m
+}
+
+func TestDraw(t *testing.T) {
+	pics := []image.Image{solid(2, 2, red), nil, solid(4, 4, blue)}
+	m := Draw(pics, &Options{
+		Columns:    2,
+		CellWidth:  4,
+		CellHeight: 4,
+		Padding:    1,
+		Background: white,
+		HAlign:     End,
+		VAlign:     Start,
+	})
+	p, ok := m.(*image.Paletted)
+	if !ok {
+		t.Fatalf("got %T, want *image.Paletted", m)
+	}
+	if want := image.Rect(0, 0, 11, 11); p.Rect != want {
+		t.Fatalf("got %v, want %v", p.Rect, want)
+	}
+	if len(p.Palette) != 3 || p.Palette[0] != white {
+		t.Errorf("got palette %v, want white, red, and blue", p.Palette)
+	}
+	for _, tt := range []struct {
+		x, y int
+		want color.Color
+	}{
+		{0, 0, white}, // padding
+		{1, 1, white}, // left of the red pic
+		{3, 1, red},
+		{4, 2, red},
+		{4, 3, white}, // below the red pic
+		{6, 1, white}, // the empty cell
+		{1, 6, blue},
+		{4, 9, blue},
+	} {
+		if got := p.At(tt.x, tt.y); got != tt.want {
+			t.Errorf("(%d,%d): got %v, want %v", tt.x, tt.y, got, tt.want)
+		}
+	}
+}
+
+func TestDrawCrop(t *testing.T) {
+	m := Draw([]image.Image{solid(4, 4, red)}, &Options{CellWidth: 2, CellHeight: 2})
+	if got, want := m.Bounds(), image.Rect(0, 0, 2, 2); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if _, _, _, a := m.At(0, 0).RGBA(); a == 0 {
+		t.Error("expected the pic to fill the cell")
+	}
+}
+
+func TestDrawManyColors(t *testing.T) {
+	var pics []image.Image
+	for i := 0; i < 300; i++ {
+		pics = append(pics, solid(1, 1, color.NRGBA{uint8(i), uint8(i >> 8), 0, 255}))
+	}
+	m := Draw(pics, nil)
+	if _, ok := m.(*image.NRGBA); !ok {
+		t.Fatalf("got %T, want *image.NRGBA", m)
+	}
+	// 300 pics make an 18x17 grid.
+	if got, want := m.Bounds(), image.Rect(0, 0, 18, 17); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := m.At(17, 0), (color.NRGBA{17, 0, 0, 255}); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
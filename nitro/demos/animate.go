@@ -3,11 +3,14 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"image/gif"
 	"os"
 	"strconv"
+	"strings"
 
+	"github.com/magical/sprites/lcd"
 	"github.com/magical/sprites/nitro"
 )
 
@@ -16,12 +19,19 @@ func die(v ...interface{}) {
 	os.Exit(1)
 }
 
+var colorCorrection = flag.String("color-correction", "none", "adjust colors to look as they would on a screen: "+strings.Join(lcd.Names(), ", "))
+
 func main() {
-	if len(os.Args) != 3 {
-		die("Usage: animate pokegra.narc 2")
+	flag.Parse()
+	if flag.NArg() != 2 {
+		die("Usage: animate [-color-correction profile] pokegra.narc 2")
 	}
-	filename := os.Args[1]
-	poke, err := strconv.ParseInt(os.Args[2], 0, 64)
+	correction, err := lcd.Lookup(*colorCorrection)
+	if err != nil {
+		die(err)
+	}
+	filename := flag.Arg(0)
+	poke, err := strconv.ParseInt(flag.Arg(1), 0, 64)
 	if err != nil {
 		die(err)
 	}
@@ -62,6 +72,12 @@ func main() {
 	}
 	g := nitro.NewAnimation(ncgr, nclr, ncer, nanr, nmcr, nmar).Render()
 	//fmt.Fprintln(os.Stderr, len(g.Image))
+	// Frames may be repeated, so correct copies rather than the frames.
+	for i, m := range g.Image {
+		c := *m
+		c.Palette = correction.Palette(m.Palette)
+		g.Image[i] = &c
+	}
 	if err := gif.EncodeAll(os.Stdout, g); err != nil {
 		die(err)
 	}
@@ -3,11 +3,16 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"image"
 	"image/color"
 	"image/png"
 	"os"
+	"strings"
 
+	"github.com/magical/sprites/lcd"
+	"github.com/magical/sprites/montage"
 	"github.com/magical/sprites/nitro"
 )
 
@@ -16,12 +21,23 @@ func die(v ...interface{}) {
 	os.Exit(1)
 }
 
+var (
+	colorCorrection = flag.String("color-correction", "none", "adjust colors to look as they would on a screen: "+strings.Join(lcd.Names(), ", "))
+	montageFlag     = flag.Bool("montage", false, "write the cells to a single montage.png instead of one file each")
+	columns         = flag.Int("columns", 0, "number of columns in a montage; 0 for a square grid")
+)
+
 func main() {
-	if len(os.Args) != 2 {
-		fmt.Printf("Usage: %s path/to/a.narc", os.Args[0])
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Printf("Usage: %s [-color-correction profile] [-montage] path/to/a.narc", os.Args[0])
 		return
 	}
-	filename := os.Args[1]
+	correction, err := lcd.Lookup(*colorCorrection)
+	if err != nil {
+		die(err)
+	}
+	filename := flag.Arg(0)
 
 	f, err := os.Open(filename)
 	if err != nil {
@@ -45,8 +61,21 @@ func main() {
 	if err != nil {
 		die("OpenNCER:", err)
 	}
-	pal := p.Palette(0)
+	pal := correction.Palette(p.Palette(0))
 	pal[0] = setAlpha(pal[0])
+	if *montageFlag {
+		var cells []image.Image
+		for i := 0; i < c.Len(); i++ {
+			cells = append(cells, c.Cell(i, g, pal))
+		}
+		f, err := os.Create("montage.png")
+		if err != nil {
+			die(err)
+		}
+		png.Encode(f, montage.Draw(cells, &montage.Options{Columns: *columns}))
+		f.Close()
+		return
+	}
 	for i := 0; i < c.Len(); i++ {
 		m := c.Cell(i, g, pal)
 		f, _ := os.Create(fmt.Sprintf("cell-%d.png", i))
@@ -3,12 +3,16 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"image"
 	"image/color"
 	"os"
+	"strings"
 
 	"github.com/magical/png"
+	"github.com/magical/sprites/lcd"
+	"github.com/magical/sprites/montage"
 	"github.com/magical/sprites/nitro"
 )
 
@@ -17,8 +21,19 @@ var _ = fmt.Println
 var Frame1Rect = image.Rect(0, 0, 80, 80)
 var Frame2Rect = image.Rect(80, 0, 160, 80)
 
+var (
+	colorCorrection = flag.String("color-correction", "none", "adjust colors to look as they would on a screen: "+strings.Join(lcd.Names(), ", "))
+	montageFlag     = flag.Bool("montage", false, "draw the first frame of every sprite in the NARC as a single montage")
+	columns         = flag.Int("columns", 15, "number of columns in a montage")
+)
+
 func main() {
-	f, err := os.Open(os.Args[1])
+	flag.Parse()
+	correction, err := lcd.Lookup(*colorCorrection)
+	if err != nil {
+		panic(err)
+	}
+	f, err := os.Open(flag.Arg(0))
 	if err != nil {
 		panic(err)
 	}
@@ -28,23 +43,44 @@ func main() {
 		panic(err)
 	}
 
-	number := 3
+	var m image.Image
+	if *montageFlag {
+		var pics []image.Image
+		for number := 0; number*6+4 < narc.FileCount(); number++ {
+			pic, _ := ripSprite(narc, number, correction) // nil if it can't be ripped
+			pics = append(pics, pic)
+		}
+		m = montage.Draw(pics, &montage.Options{Columns: *columns})
+	} else {
+		m, err = ripSprite(narc, 3, correction)
+		if err != nil {
+			panic(err)
+		}
+	}
+	sBIT := uint(5)
+	if *colorCorrection != "none" {
+		sBIT = 8
+	}
+	png.EncodeWithSBIT(os.Stdout, m, sBIT)
+}
 
+// RipSprite returns the first frame of a sprite.
+func ripSprite(narc *nitro.NARC, number int, correction lcd.Profile) (image.Image, error) {
 	ncgr, err := narc.OpenNCGR(number*6 + 2)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	nclr, err := narc.OpenNCLR(number*6 + 4)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	ncgr.DecryptReverse()
-	pal := nclr.Palette(0)
+	pal := correction.Palette(nclr.Palette(0))
 	pal[0] = setTransparent(pal[0])
 	//fmt.Fprintln(os.Stderr, pal)
 	m := ncgr.Image(pal).SubImage(Frame1Rect)
 	//fmt.Fprintln(os.Stderr, m.(*image.Paletted).Pix)
-	png.EncodeWithSBIT(os.Stdout, m, 5)
+	return m, nil
 }
 
 func setTransparent(c color.Color) color.Color {
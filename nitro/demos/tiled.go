@@ -3,13 +3,16 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"image"
 	"image/draw"
 	"image/png"
 	"os"
 	"strconv"
+	"strings"
 
+	"github.com/magical/sprites/lcd"
 	"github.com/magical/sprites/nitro"
 )
 
@@ -18,17 +21,24 @@ func die(v interface{}) {
 	os.Exit(1)
 }
 
+var colorCorrection = flag.String("color-correction", "none", "adjust colors to look as they would on a screen: "+strings.Join(lcd.Names(), ", "))
+
 func main() {
-	if len(os.Args) != 4 {
-		fmt.Println("Usage: tiled path/to/a.narc number palette")
+	flag.Parse()
+	if flag.NArg() != 3 {
+		fmt.Println("Usage: tiled [-color-correction profile] path/to/a.narc number palette")
 		return
 	}
-	filename := os.Args[1]
-	number, err := strconv.ParseInt(os.Args[2], 0, 0)
+	correction, err := lcd.Lookup(*colorCorrection)
+	if err != nil {
+		die(err)
+	}
+	filename := flag.Arg(0)
+	number, err := strconv.ParseInt(flag.Arg(1), 0, 0)
 	if err != nil {
 		die(err)
 	}
-	npalette, err := strconv.ParseInt(os.Args[3], 0, 0)
+	npalette, err := strconv.ParseInt(flag.Arg(2), 0, 0)
 	if err != nil {
 		die(err)
 	}
@@ -50,7 +60,7 @@ func main() {
 	if err != nil {
 		die(err)
 	}
-	pal := nclr.Palette(0)
+	pal := correction.Palette(nclr.Palette(0))
 	fmt.Fprintln(os.Stderr, n.Bounds())
 	//m := n.Tile(0, 96, 96
 	//m.Palette = nclr.Palette(0)
@@ -0,0 +1,274 @@
+package sprites
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"sort"
+)
+
+var ErrNoSpace = errors.New("not enough free space in the sprite banks")
+
+// A Patcher writes edited pics into a copy of a Gold, Silver, or Crystal ROM.
+//
+// A pic which compresses to no more than its old size is written in place.
+// Otherwise it is moved to the free space at the end of one of the banks
+// which already hold pics; the space it used to occupy is not reused.
+// Pics never cross a bank boundary, since the game can't read them if they do.
+//
+// A Patcher is not safe for concurrent use.
+type Patcher struct {
+	rip  *Ripper
+	free []span        // free space at the end of each sprite bank, by offset
+	refs map[int64]int // number of pointers to each pic
+}
+
+// A span is a range of ROM offsets.
+type span struct {
+	start, end int64
+}
+
+// NewPatcher reads a ROM and finds the free space in its sprite banks.
+func NewPatcher(r io.Reader) (*Patcher, error) {
+	rip, err := NewRipper(r)
+	if err != nil {
+		return nil, err
+	}
+	p := &Patcher{rip: rip}
+	p.findFreeSpace()
+	return p, nil
+}
+
+// Ripper returns a Ripper which reads from the patched ROM.
+// It must not be used concurrently with the Patcher.
+func (p *Patcher) Ripper() *Ripper {
+	return p.rip
+}
+
+// FindFreeSpace records the unused space at the end of every bank that
+// holds a pic. Space is unused if it lies past the end of the last pic in
+// the bank and is filled with 0x00 or 0xFF. A bank in which anything else
+// follows the last pic holds more than pics, so none of it is used: the
+// fill bytes could belong to that data. Data that is itself nothing but
+// fill bytes can't be told apart from free space.
+func (p *Patcher) findFreeSpace() {
+	rip := p.rip
+	p.refs = map[int64]int{}
+	last := map[int64]int64{} // bank -> end of the last pic in it
+	addPic := func(table string, base int64, n int) {
+		off, err := rip.farPointer(table, base, n)
+		if err != nil || off >= int64(len(rip.rom)) {
+			return
+		}
+		p.refs[off]++
+		r := rip.at(off)
+		if _, err := decodeTiles(r, 0); err != nil {
+			return
+		}
+		end := off + r.Size() - int64(r.Len())
+		bank := off / bankSize
+		if end > last[bank] {
+			last[bank] = end
+		}
+	}
+	for i := 0; i < MaxPokemon*2; i++ {
+		addPic("sprite", rip.info.SpriteOffset, i)
+	}
+	if rip.info.UnownSpriteOffset != 0 {
+		for i := 0; i < len(UnownForms)*2; i++ {
+			addPic("unown sprite", rip.info.UnownSpriteOffset, i)
+		}
+	}
+	if rip.info.TrainerOffset != 0 {
+		for i := 0; i < MaxTrainer; i++ {
+			addPic("trainer", rip.info.TrainerOffset, i)
+		}
+	}
+
+	p.free = nil
+	for bank, end := range last {
+		bankEnd := (bank + 1) * bankSize
+		if bankEnd > int64(len(rip.rom)) {
+			continue
+		}
+		fill := rip.rom[bankEnd-1]
+		if fill != 0x00 && fill != 0xFF {
+			continue
+		}
+		start := bankEnd
+		for start > end && rip.rom[start-1] == fill {
+			start--
+		}
+		if start > end {
+			continue
+		}
+		if start < bankEnd {
+			p.free = append(p.free, span{start, bankEnd})
+		}
+	}
+	sort.Slice(p.free, func(i, j int) bool { return p.free[i].start < p.free[j].start })
+}
+
+// FreeSpace returns the number of bytes available for moved pics.
+func (p *Patcher) FreeSpace() int {
+	var n int64
+	for _, s := range p.free {
+		n += s.end - s.start
+	}
+	return int(n)
+}
+
+// SetPokemon replaces a Pokémon's front pic. The image must be the same size
+// as the old pic; its colors are mapped to the nearest color in the
+// Pokémon's palette. Any extra tiles used by the animations are kept.
+func (p *Patcher) SetPokemon(number int, m image.Image) error {
+	if 1 > number || number > MaxPokemon {
+		return ErrNoSuchPokemon
+	}
+	w, h, err := p.rip.pokemonSize(number)
+	if err != nil {
+		return err
+	}
+	return p.setPic(number, 0, front, w, h, m)
+}
+
+// SetPokemonBack replaces a Pokémon's back pic, which must be 48x48.
+func (p *Patcher) SetPokemonBack(number int, m image.Image) error {
+	if 1 > number || number > MaxPokemon {
+		return ErrNoSuchPokemon
+	}
+	return p.setPic(number, 0, back, 6, 6, m)
+}
+
+// SetUnown replaces the front pic of an Unown form.
+func (p *Patcher) SetUnown(form string, m image.Image) error {
+	if len(form) != 1 || 'a' > form[0] || form[0] > 'z' {
+		return ErrNoSuchPokemon
+	}
+	w, h, err := p.rip.pokemonSize(201)
+	if err != nil {
+		return err
+	}
+	return p.setPic(201, int(form[0]-'a'), front, w, h, m)
+}
+
+// SetUnownBack replaces the back pic of an Unown form.
+func (p *Patcher) SetUnownBack(form string, m image.Image) error {
+	if len(form) != 1 || 'a' > form[0] || form[0] > 'z' {
+		return ErrNoSuchPokemon
+	}
+	return p.setPic(201, int(form[0]-'a'), back, 6, 6, m)
+}
+
+func (p *Patcher) setPic(number, form, facing, w, h int, m image.Image) error {
+	if dx, dy := m.Bounds().Dx(), m.Bounds().Dy(); dx != w*8 || dy != h*8 {
+		return fmt.Errorf("image is %dx%d, want %dx%d", dx, dy, w*8, h*8)
+	}
+	pal, err := p.rip.pokemonPalette(number, normal)
+	if err != nil {
+		return err
+	}
+	old, err := p.rip.rawPic(number, form, facing, w, h)
+	if err != nil {
+		return err
+	}
+	tiledata := tile(quantize(m, pal))
+	tiledata = append(tiledata, old.Tiles[len(tiledata):]...)
+	data := encodeTiles(tiledata)
+
+	table, base, n := p.rip.pokemonPointer(number, form, facing)
+	off, err := p.rip.farPointer(table, base, n)
+	if err != nil {
+		return err
+	}
+	// Pics that are shared with another entry can't be overwritten.
+	if len(data) <= len(old.Compressed) && p.refs[off] <= 1 {
+		copy(p.rip.rom[off:], data)
+		return nil
+	}
+	newoff, err := p.alloc(len(data))
+	if err != nil {
+		return err
+	}
+	ptr, err := p.farPointerBytes(newoff)
+	if err != nil {
+		return err
+	}
+	copy(p.rip.rom[newoff:], data)
+	copy(p.rip.rom[base+3*int64(n):], ptr[:])
+	p.refs[off]--
+	p.refs[newoff]++
+	return nil
+}
+
+// Alloc takes size bytes from the free space, all from the same bank.
+func (p *Patcher) alloc(size int) (int64, error) {
+	for i := range p.free {
+		s := &p.free[i]
+		if s.end-s.start >= int64(size) {
+			off := s.start
+			s.start += int64(size)
+			return off, nil
+		}
+	}
+	return 0, ErrNoSpace
+}
+
+// FarPointerBytes encodes a pointer to a pic, undoing the bank fixup
+// applied by fixFarPointer.
+func (p *Patcher) farPointerBytes(off int64) (b [3]byte, err error) {
+	crystal := p.rip.info.Title == "PM_CRYSTAL"
+	bank := off / bankSize
+	if crystal {
+		bank -= 0x36
+	} else {
+		switch bank {
+		case 0x1F, 0x20:
+			bank -= 0xC
+		case 0x2E:
+			bank -= 0xF
+		}
+	}
+	ptr := bank*bankSize + off%bankSize
+	if bank < 0 || bank > 0xFF || fixFarPointer(ptr, crystal) != off {
+		return b, fmt.Errorf("can't point to a pic at %#x", off)
+	}
+	b[0] = byte(bank)
+	b[1] = byte(off)
+	b[2] = byte(0x40 | off>>8&0x3F)
+	return b, nil
+}
+
+// Quantize maps the colors of m to the nearest colors in pal.
+// Transparent pixels become color 0.
+func quantize(m image.Image, pal color.Palette) *image.Paletted {
+	r := m.Bounds()
+	q := image.NewPaletted(image.Rect(0, 0, r.Dx(), r.Dy()), pal)
+	for y := 0; y < r.Dy(); y++ {
+		for x := 0; x < r.Dx(); x++ {
+			c := m.At(r.Min.X+x, r.Min.Y+y)
+			if _, _, _, a := c.RGBA(); a == 0 {
+				continue
+			}
+			q.Pix[q.PixOffset(x, y)] = uint8(pal.Index(c))
+		}
+	}
+	return q
+}
+
+// WriteTo writes the patched ROM to w, with its global checksum updated.
+func (p *Patcher) WriteTo(w io.Writer) (int64, error) {
+	rom := p.rip.rom
+	var sum uint16
+	for i, b := range rom {
+		if i != 0x14E && i != 0x14F {
+			sum += uint16(b)
+		}
+	}
+	rom[0x14E] = byte(sum >> 8)
+	rom[0x14F] = byte(sum)
+	n, err := w.Write(rom)
+	return int64(n), err
+}
@@ -0,0 +1,160 @@
+package sprites
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+)
+
+func TestFarPointerBytes(t *testing.T) {
+	for _, title := range []string{"POKEMON_GLD", "PM_CRYSTAL"} {
+		p := &Patcher{rip: &Ripper{info: RomInfo{Title: title}}}
+		crystal := title == "PM_CRYSTAL"
+		for _, off := range []int64{0x48123, 0x7C000, 0x1F << 14, 0x20<<14 + 0x3FFF, 0x2E<<14 + 0x100, 0x120000, 0x13FFFF} {
+			b, err := p.farPointerBytes(off)
+			if err != nil {
+				if crystal && off < 0x36<<14 {
+					continue
+				}
+				t.Errorf("%s: farPointerBytes(%#x): %v", title, off, err)
+				continue
+			}
+			if got := fixFarPointer(readFarPointer(b[:]), crystal); got != off {
+				t.Errorf("%s: farPointerBytes(%#x) points to %#x", title, off, got)
+			}
+		}
+	}
+	p := &Patcher{rip: &Ripper{info: RomInfo{Title: "POKEMON_GLD"}}}
+	if _, err := p.farPointerBytes(0x13 << 14); err == nil {
+		t.Errorf("farPointerBytes(%#x): expected an error for an unreachable bank", 0x13<<14)
+	}
+}
+
+func TestQuantize(t *testing.T) {
+	pal := color.Palette{color.White, RGB15(0x001F), RGB15(0x7C00), color.Black}
+	m := image.NewRGBA(image.Rect(0, 0, 4, 1))
+	m.Set(0, 0, color.Transparent)
+	m.Set(1, 0, color.RGBA{0xF0, 0x10, 0x10, 0xFF})
+	m.Set(2, 0, color.RGBA{0x10, 0x10, 0xE0, 0xFF})
+	m.Set(3, 0, color.RGBA{0x20, 0x20, 0x20, 0xFF})
+	q := quantize(m, pal)
+	want := []uint8{0, 1, 2, 3}
+	for i, p := range q.Pix {
+		if p != want[i] {
+			t.Errorf("pixel %d: got %d, want %d", i, p, want[i])
+		}
+	}
+}
+
+var patchPalette = color.Palette{color.White, RGB15(0x001F), RGB15(0x03E0), color.Black}
+
+// NewTestPatcher builds a Gold ROM in which Bulbasaur's 5x5 front pic is old,
+// at 2:4000, and every other pic is a blank one ending at picEnd. The rest
+// of bank 2 is free.
+func newTestPatcher(old []byte, picEnd int64) *Patcher {
+	rom := make([]byte, 0x10000)
+	info := RomInfo{Title: "POKEMON_GLD", StatsOffset: 0x1000, PaletteOffset: 0x2000, SpriteOffset: 0x3000}
+	rom[0x1000+spriteSizeIndex] = 0x55
+	copy(rom[0x2000+2*4:], []byte{0x1F, 0x00, 0xE0, 0x03})
+
+	blank := encodeTiles(make([]byte, 6*6*16))
+	blankOff := picEnd - int64(len(blank))
+	copy(rom[0x8000:], old)
+	copy(rom[blankOff:], blank)
+	for i := 0; i < MaxPokemon*2; i++ {
+		off := blankOff
+		if i == 0 {
+			off = 0x8000
+		}
+		copy(rom[0x3000+3*i:], []byte{byte(off >> 14), byte(off), byte(0x40 | off>>8&0x3F)})
+	}
+	p := &Patcher{rip: newTestRipper(rom, info)}
+	p.findFreeSpace()
+	return p
+}
+
+// TestPic returns a 5x5 pic which compresses well if simple is true, and
+// poorly otherwise.
+func testPic(simple bool, seed int64) *image.Paletted {
+	m := image.NewPaletted(image.Rect(0, 0, 40, 40), patchPalette)
+	rng := rand.New(rand.NewSource(seed))
+	for i := range m.Pix {
+		if simple {
+			m.Pix[i] = uint8(i / 40 % 4)
+		} else {
+			m.Pix[i] = uint8(rng.Intn(4))
+		}
+	}
+	return m
+}
+
+func TestSetPokemon(t *testing.T) {
+	p := newTestPatcher(encodeTiles(tile(testPic(false, 1))), 0x8800)
+	if got, want := p.FreeSpace(), 0xC000-0x8800; got != want {
+		t.Fatalf("got %#x bytes free, want %#x", got, want)
+	}
+	check := func(what string, m *image.Paletted, wantOff int64) {
+		off, err := p.rip.pokemonOffset(1, 0, front)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if off != wantOff {
+			t.Errorf("%s: pic is at %#x, want %#x", what, off, wantOff)
+		}
+		got, err := p.Ripper().Pokemon(1)
+		if err != nil {
+			t.Fatalf("%s: %v", what, err)
+		}
+		if !bytes.Equal(got.Pix, m.Pix) {
+			t.Errorf("%s: pic doesn't decode to the image", what)
+		}
+	}
+
+	// A pic which compresses better is written in place.
+	m := testPic(true, 0)
+	if err := p.SetPokemon(1, m); err != nil {
+		t.Fatal(err)
+	}
+	check("smaller", m, 0x8000)
+
+	// A larger one is moved to the start of the free space.
+	m = testPic(false, 2)
+	if err := p.SetPokemon(1, m); err != nil {
+		t.Fatal(err)
+	}
+	check("larger", m, 0x8800)
+	if got, want := p.FreeSpace(), 0xC000-0x8800-len(encodeTiles(tile(m))); got != want {
+		t.Errorf("got %#x bytes free after moving the pic, want %#x", got, want)
+	}
+}
+
+func TestSetPokemonNoSpace(t *testing.T) {
+	// Only four bytes are free at the end of the bank.
+	p := newTestPatcher(encodeTiles(tile(testPic(true, 0))), 0xC000-4)
+	if got := p.FreeSpace(); got != 4 {
+		t.Fatalf("got %d bytes free, want 4", got)
+	}
+	before := append([]byte(nil), p.rip.rom...)
+	if err := p.SetPokemon(1, testPic(false, 1)); err != ErrNoSpace {
+		t.Errorf("got %v, want %v", err, ErrNoSpace)
+	}
+	if !bytes.Equal(p.rip.rom, before) {
+		t.Error("the ROM was modified")
+	}
+}
+
+func TestFreeSpaceAfterData(t *testing.T) {
+	// Something other than a pic follows the last one, so the zeros after
+	// it may not be free.
+	p := newTestPatcher(encodeTiles(tile(testPic(true, 0))), 0x8800)
+	copy(p.rip.rom[0x9000:], []byte{0x12, 0x34, 0x00, 0x00})
+	p.findFreeSpace()
+	if got := p.FreeSpace(); got != 0 {
+		t.Fatalf("got %#x bytes free, want 0", got)
+	}
+	if err := p.SetPokemon(1, testPic(false, 1)); err != ErrNoSpace {
+		t.Errorf("got %v, want %v", err, ErrNoSpace)
+	}
+}
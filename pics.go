@@ -0,0 +1,253 @@
+package sprites
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"sort"
+	"strconv"
+)
+
+// The egg follows Celebi in the pic pointer and palette tables.
+const eggNumber = MaxPokemon + 1
+
+// Egg returns the pic shown for an egg in the party status screen.
+func (rip *Ripper) Egg() (*image.Paletted, error) {
+	return rip.pokemonPic(eggNumber, 0, front, 5, 5)
+}
+
+// HasIcons reports whether the party menu icons were found in the ROM.
+func (rip *Ripper) HasIcons() bool {
+	return rip.info.MenuIconsOffset != 0 && rip.info.IconPointersOffset != 0 && rip.info.IconBank != 0
+}
+
+// HasFootprints reports whether the Pokédex footprints were found in the
+// ROM.
+func (rip *Ripper) HasFootprints() bool {
+	return rip.info.FootprintOffset != 0
+}
+
+// Icon returns the two frames of a Pokémon's 16x16 party menu icon, in
+// shades of gray. It returns ErrNotFound if the ROM has no icons.
+func (rip *Ripper) Icon(number int) ([]*image.Paletted, error) {
+	if 1 > number || number > MaxPokemon {
+		return nil, ErrNoSuchPokemon
+	}
+	if !rip.HasIcons() {
+		return nil, ErrNotFound
+	}
+	var b [1]byte
+	off := rip.info.MenuIconsOffset + int64(number-1)
+	_, err := rip.r.ReadAt(b[:], off)
+	if err != nil {
+		return nil, &TableError{"menu icon", number - 1, off, noEOF(err)}
+	}
+	icon := int(b[0])
+	off, err = readNearPointerAt(rip.r, "icon", rip.info.IconPointersOffset, icon)
+	if err != nil {
+		return nil, err
+	}
+	off = rip.info.IconBank*bankSize + off%bankSize
+
+	// Each frame is four tiles, stored in row-major order.
+	data := make([]byte, 2*4*16)
+	_, err = rip.r.ReadAt(data, off)
+	if err != nil {
+		return nil, &TableError{"icon", icon, off, noEOF(err)}
+	}
+	frames := make([]*image.Paletted, 2)
+	for i := range frames {
+		m := image.NewPaletted(image.Rect(0, 0, 16, 16), defaultPalette)
+		untileRows(m, data[i*4*16:])
+		frames[i] = m
+	}
+	return frames, nil
+}
+
+// UntileRows is like untile, but for tiles stored in row-major order.
+func untileRows(m *image.Paletted, data []byte) {
+	r := m.Rect
+	for y := r.Min.Y; y < r.Max.Y; y += 8 {
+		for x := r.Min.X; x < r.Max.X; x += 8 {
+			untile(m.SubImage(image.Rect(x, y, x+8, y+8)).(*image.Paletted), data)
+			data = data[16:]
+		}
+	}
+}
+
+// FootprintPalette is used for footprints: white background, black print.
+var footprintPalette = color.Palette{color.White, color.Black}
+
+// Footprint returns a Pokémon's 16x16 Pokédex footprint. It returns
+// ErrNotFound if the ROM has no footprints.
+func (rip *Ripper) Footprint(number int) (*image.Paletted, error) {
+	if 1 > number || number > MaxPokemon {
+		return nil, ErrNoSuchPokemon
+	}
+	if !rip.HasFootprints() {
+		return nil, ErrNotFound
+	}
+	// Footprints are 1bpp and come in groups of eight: the top halves
+	// of all eight, then the bottom halves.
+	i := int64(number - 1)
+	group := rip.info.FootprintOffset + i/8*256
+	m := image.NewPaletted(image.Rect(0, 0, 16, 16), footprintPalette)
+	for half, off := range []int64{group + i%8*16, group + 128 + i%8*16} {
+		var data [16]byte
+		_, err := rip.r.ReadAt(data[:], off)
+		if err != nil {
+			return nil, &TableError{"footprint", number - 1, off, noEOF(err)}
+		}
+		// Two tiles side by side.
+		for t := 0; t < 2; t++ {
+			for y := 0; y < 8; y++ {
+				row := data[t*8+y]
+				for x := 0; x < 8; x++ {
+					m.SetColorIndex(t*8+x, half*8+y, row>>uint(7-x)&1)
+				}
+			}
+		}
+	}
+	return m, nil
+}
+
+// BackpicNames returns the names of the extra back pics known for the ROM,
+// such as "chris" and "kris", in sorted order.
+func (rip *Ripper) BackpicNames() []string {
+	var names []string
+	for name := range rip.info.Backpics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Backpic returns one of the back pics named by BackpicNames.
+func (rip *Ripper) Backpic(name string) (*image.Paletted, error) {
+	pic, ok := rip.info.Backpics[name]
+	if !ok {
+		return nil, ErrNoSuchSprite
+	}
+	pal := defaultPalette
+	if pic.PaletteOffset != 0 {
+		var err error
+		pal, err = ripPalette(rip.r, name+" backpic palette", pic.PaletteOffset, 0)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var m *image.Paletted
+	if pic.Compressed {
+		var err error
+		m, err = rip.decode(pic.Offset, pic.Width, pic.Height)
+		if err != nil {
+			return nil, &TableError{name + " backpic", 0, pic.Offset, err}
+		}
+	} else {
+		data := make([]byte, pic.Width*pic.Height*16)
+		_, err := rip.r.ReadAt(data, pic.Offset)
+		if err != nil {
+			return nil, &TableError{name + " backpic", 0, pic.Offset, noEOF(err)}
+		}
+		m = image.NewPaletted(image.Rect(0, 0, pic.Width*8, pic.Height*8), nil)
+		untile(m, data)
+	}
+	m.Palette = pal
+	return m, nil
+}
+
+// Overworld sprites are described by a table of six-byte entries:
+// a near pointer, the size in bytes, the bank, the type, and the palette.
+const overworldEntrySize = 6
+
+// Overworld sprite types.
+const (
+	walkingSprite  = 1
+	standingSprite = 2
+	stillSprite    = 3
+)
+
+// A SpriteSheet is a set of 16x16 frames stacked vertically in one image.
+type SpriteSheet struct {
+	Image   *image.Paletted
+	Frames  []SpriteFrame
+	Palette int // the overworld palette number the game uses
+}
+
+// MarshalJSON encodes the frame metadata of the sheet, without the image.
+func (s *SpriteSheet) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Frames  []SpriteFrame `json:"frames"`
+		Palette int           `json:"palette"`
+	}{s.Frames, s.Palette})
+}
+
+// A SpriteFrame names a frame of a sprite sheet and gives its position.
+type SpriteFrame struct {
+	Name string `json:"name"`
+	X    int    `json:"x"`
+	Y    int    `json:"y"`
+	W    int    `json:"w"`
+	H    int    `json:"h"`
+}
+
+// The frames of a walking sprite. Standing sprites have only the first three.
+// Facing right is drawn by flipping the left frames.
+var walkingFrames = []string{"down", "up", "left", "down-walk", "up-walk", "left-walk"}
+
+// HasOverworld reports whether the overworld sprites were found in the ROM.
+func (rip *Ripper) HasOverworld() bool {
+	return rip.info.OverworldOffset != 0 && rip.info.NumOverworld != 0
+}
+
+// NumOverworld returns the number of overworld sprites.
+func (rip *Ripper) NumOverworld() int {
+	return rip.info.NumOverworld
+}
+
+// Overworld returns an overworld sprite sheet, in shades of gray.
+// Sprites are numbered from 1. It returns ErrNotFound if the ROM has no
+// overworld sprites.
+func (rip *Ripper) Overworld(number int) (*SpriteSheet, error) {
+	if !rip.HasOverworld() {
+		return nil, ErrNotFound
+	}
+	if 1 > number || number > rip.info.NumOverworld {
+		return nil, ErrNoSuchSprite
+	}
+	var e [overworldEntrySize]byte
+	off := rip.info.OverworldOffset + int64(number-1)*overworldEntrySize
+	_, err := rip.r.ReadAt(e[:], off)
+	if err != nil {
+		return nil, &TableError{"overworld", number - 1, off, noEOF(err)}
+	}
+	ptr := int64(e[3])*bankSize + int64(e[1])&0x3F<<8 + int64(e[0])
+	size := int(e[2])
+	kind, palette := e[4], int(e[5])
+
+	// Walking sprites have a second set of tiles for the walking frames.
+	if kind == walkingSprite {
+		size *= 2
+	}
+	nframes := size / (4 * 16)
+	if nframes == 0 {
+		return nil, &TableError{"overworld", number - 1, off, ErrMalformed}
+	}
+	data := make([]byte, nframes*4*16)
+	_, err = rip.r.ReadAt(data, ptr)
+	if err != nil {
+		return nil, &TableError{"overworld", number - 1, ptr, noEOF(err)}
+	}
+
+	sheet := &SpriteSheet{Palette: palette}
+	sheet.Image = image.NewPaletted(image.Rect(0, 0, 16, 16*nframes), defaultPalette)
+	untileRows(sheet.Image, data)
+	for i := 0; i < nframes; i++ {
+		name := strconv.Itoa(i)
+		if kind != stillSprite && i < len(walkingFrames) {
+			name = walkingFrames[i]
+		}
+		sheet.Frames = append(sheet.Frames, SpriteFrame{name, 0, 16 * i, 16, 16})
+	}
+	return sheet, nil
+}
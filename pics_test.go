@@ -0,0 +1,208 @@
+package sprites
+
+import (
+	"bytes"
+	"testing"
+)
+
+func newTestRipper(rom []byte, info RomInfo) *Ripper {
+	return &Ripper{rom: rom, r: bytes.NewReader(rom), info: info}
+}
+
+func TestFootprint(t *testing.T) {
+	rom := make([]byte, 0x4000)
+	// Footprint 10 is the second in its group. Fill its top-left and
+	// bottom-right tiles.
+	for y := 0; y < 8; y++ {
+		rom[0x1000+256+16+y] = 0xFF
+		rom[0x1000+256+128+16+8+y] = 0xFF
+	}
+	rip := newTestRipper(rom, RomInfo{FootprintOffset: 0x1000})
+	m, err := rip.Footprint(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			var want uint8
+			if x < 8 == (y < 8) {
+				want = 1
+			}
+			if got := m.ColorIndexAt(x, y); got != want {
+				t.Fatalf("pixel (%d,%d): got %d, want %d", x, y, got, want)
+			}
+		}
+	}
+	if _, err := newTestRipper(rom, RomInfo{}).Footprint(10); err != ErrNotFound {
+		t.Errorf("without footprints: got %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestIcon(t *testing.T) {
+	rom := make([]byte, 0x8000)
+	rom[0x100+24] = 3                             // Pikachu uses icon 3
+	rom[0x200+3*2], rom[0x200+3*2+1] = 0x00, 0x50 // icon 3 is at 1:5000
+	// Second frame, top-right tile: color 3 everywhere.
+	for i := 0; i < 16; i++ {
+		rom[0x5000+(4+1)*16+i] = 0xFF
+	}
+	rip := newTestRipper(rom, RomInfo{MenuIconsOffset: 0x100, IconPointersOffset: 0x200, IconBank: 1})
+	frames, err := rip.Icon(25)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 2 {
+		t.Fatalf("got %d frames, want 2", len(frames))
+	}
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			var want uint8
+			if x >= 8 && y < 8 {
+				want = 3
+			}
+			if got := frames[1].ColorIndexAt(x, y); got != want {
+				t.Fatalf("pixel (%d,%d): got %d, want %d", x, y, got, want)
+			}
+			if got := frames[0].ColorIndexAt(x, y); got != 0 {
+				t.Fatalf("frame 0 pixel (%d,%d): got %d, want 0", x, y, got)
+			}
+		}
+	}
+	if _, err := newTestRipper(rom, RomInfo{}).Icon(25); err != ErrNotFound {
+		t.Errorf("without icons: got %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestFindIconsAndFootprints(t *testing.T) {
+	rom := make([]byte, 0x10000)
+	// ld de, Footprints (3:4000) ... lb bc, BANK(Footprints), 2
+	copy(rom[0x4100:], []byte{0x11, 0x00, 0x40, 0x19, 0xE5, 0x5D, 0x54, 0x21, 0x20, 0x96, 0x01, 0x02, 0x03})
+	// ld hl, MonMenuIcons (2:7000) ... ld a, BANK(MonMenuIcons)
+	copy(rom[0x4200:], []byte{0xFE, 0xFD, 0x28, 0x03, 0x3D, 0x21, 0x00, 0x70, 0x5F, 0x16, 0x00, 0x19, 0x3E, 0x02})
+	// ld de, IconPointers (1:6000) ... lb bc, BANK(Icons), 8
+	copy(rom[0x4300:], []byte{0x26, 0x00, 0x29, 0x11, 0x00, 0x60, 0x19, 0x2A, 0x5F, 0x56, 0xE1, 0x01, 0x08, 0x02})
+
+	if got, want := findFootprints(rom), int64(0xC000); got != want {
+		t.Errorf("footprints: got %#x, want %#x", got, want)
+	}
+	if got, want := findMenuIcons(rom), int64(0xB000); got != want {
+		t.Errorf("menu icons: got %#x, want %#x", got, want)
+	}
+	off, bank := findIconPointers(rom)
+	if off != 0x6000 || bank != 2 {
+		t.Errorf("icon pointers: got %#x in bank %d, want 0x6000 in bank 2", off, bank)
+	}
+
+	// Without the bank, the menu icons are in the same bank as the code.
+	rom[0x4200+12] = 0x7E
+	if got, want := findMenuIcons(rom), int64(0x7000); got != want {
+		t.Errorf("near menu icons: got %#x, want %#x", got, want)
+	}
+
+	if findFootprints(make([]byte, 0x8000)) != 0 || findMenuIcons(make([]byte, 0x8000)) != 0 {
+		t.Error("found tables in an empty ROM")
+	}
+}
+
+func TestOverworld(t *testing.T) {
+	rom := make([]byte, 0x8000)
+	// Sprite 2 is a walking sprite of 12 tiles at 1:4800.
+	copy(rom[0x100+6:], []byte{0x00, 0x48, 12 * 16, 0x01, walkingSprite, 3})
+	// Mark the top-left tile of the left-walk frame.
+	for i := 0; i < 16; i++ {
+		rom[0x4800+5*4*16+i] = 0xFF
+	}
+	rip := newTestRipper(rom, RomInfo{OverworldOffset: 0x100, NumOverworld: 2})
+	s, err := rip.Overworld(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Frames) != 6 || s.Image.Rect.Dy() != 96 {
+		t.Fatalf("got %d frames in a %v image, want 6 frames", len(s.Frames), s.Image.Rect)
+	}
+	if f := s.Frames[5]; f.Name != "left-walk" || f.Y != 80 {
+		t.Errorf("got frame %+v, want left-walk at y=80", f)
+	}
+	if s.Image.ColorIndexAt(0, 80) != 3 || s.Image.ColorIndexAt(8, 80) != 0 || s.Image.ColorIndexAt(0, 79) != 0 {
+		t.Error("left-walk frame is in the wrong place")
+	}
+	if s.Palette != 3 {
+		t.Errorf("got palette %d, want 3", s.Palette)
+	}
+	if _, err := rip.Overworld(3); err != ErrNoSuchSprite {
+		t.Errorf("Overworld(3): got %v, want %v", err, ErrNoSuchSprite)
+	}
+	if _, err := newTestRipper(rom, RomInfo{}).Overworld(1); err != ErrNotFound {
+		t.Errorf("without sprites: got %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestFindOverworld(t *testing.T) {
+	rom := make([]byte, 0x8000)
+	// ld hl, OverworldSprites (1:5000) in GetSprite, and a read of the
+	// palette field at OverworldSprites + 5 elsewhere.
+	tail := []byte{0x3D, 0x4F, 0x06, 0x00, 0x3E, 0x06, 0xCD}
+	copy(rom[0x4100:], append([]byte{0x21, 0x05, 0x50}, tail...))
+	copy(rom[0x4200:], append([]byte{0x21, 0x00, 0x50}, tail...))
+	// Two sprites at 1:6000, then a bad entry.
+	copy(rom[0x5000:], []byte{
+		0x00, 0x60, 12 * 16, 0x01, walkingSprite, 0,
+		0xC0, 0x60, 4 * 16, 0x01, stillSprite, 7,
+		0x00, 0x60, 12 * 16, 0x01, 9, 0,
+	})
+	off, n := findOverworld(rom)
+	if off != 0x5000 || n != 2 {
+		t.Errorf("got %d sprites at %#x, want 2 at 0x5000", n, off)
+	}
+}
+
+func TestFindBackpics(t *testing.T) {
+	rom := make([]byte, 0x10000)
+	// ld b, BANK(ChrisBackpic); ld hl, ChrisBackpic (2:4000)
+	copy(rom[0x4100:], []byte{0x06, 0x02, 0x21, 0x00, 0x40, 0x11, 0x10, 0x93, 0x0E, 0x31})
+	// ld de, KrisBackpic (3:5000); lb bc, BANK(KrisBackpic), 49; call
+	copy(rom[0x4200:], []byte{0x11, 0x00, 0x50, 0x21, 0x10, 0x93, 0x01, 0x31, 0x03, 0xCD})
+	pics := findBackpics(rom, RomInfo{TrainerPaletteOffset: 0x1004})
+	want := map[string]PicInfo{
+		"chris": {Offset: 0x8000, Width: 6, Height: 6, Compressed: true, PaletteOffset: 0x1000},
+		"kris":  {Offset: 0xD000, Width: 6, Height: 6},
+	}
+	if len(pics) != len(want) {
+		t.Fatalf("got %v, want %v", pics, want)
+	}
+	for name, pic := range want {
+		if pics[name] != pic {
+			t.Errorf("%s: got %+v, want %+v", name, pics[name], pic)
+		}
+	}
+	if findBackpics(make([]byte, 0x8000), RomInfo{}) != nil {
+		t.Error("found back pics in an empty ROM")
+	}
+}
+
+func TestBackpic(t *testing.T) {
+	rom := make([]byte, 0x1000)
+	for i := 0; i < 16; i++ {
+		rom[0x200+i] = 0xFF // first tile is color 3
+	}
+	copy(rom[0x100:], []byte{0x1F, 0x00, 0xE0, 0x03})
+	rip := newTestRipper(rom, RomInfo{Backpics: map[string]PicInfo{
+		"kris": {Offset: 0x200, Width: 6, Height: 6, PaletteOffset: 0x100},
+	}})
+	if names := rip.BackpicNames(); len(names) != 1 || names[0] != "kris" {
+		t.Fatalf("got names %v", names)
+	}
+	m, err := rip.Backpic("kris")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ColorIndexAt(7, 7) != 3 || m.ColorIndexAt(0, 8) != 0 {
+		t.Error("tiles are in the wrong place")
+	}
+	if m.Palette[1] != RGB15(0x001F) || m.Palette[2] != RGB15(0x03E0) {
+		t.Errorf("got palette %v", m.Palette)
+	}
+	if _, err := rip.Backpic("chris"); err != ErrNoSuchSprite {
+		t.Errorf("got %v, want %v", err, ErrNoSuchSprite)
+	}
+}
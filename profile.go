@@ -0,0 +1,92 @@
+package sprites
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Profiles registered at runtime, by title. These take precedence over
+// romtab and are not checked against the ROM's contents.
+var profiles = map[string]RomInfo{}
+
+// RegisterProfile makes info available to NewRipper for ROMs with a
+// matching title, replacing any built-in or previously registered profile.
+// It is not safe to call RegisterProfile concurrently with NewRipper.
+func RegisterProfile(info RomInfo) error {
+	if info.Title == "" {
+		return errors.New("profile has no title")
+	}
+	if info.Version == "" {
+		return fmt.Errorf("profile %s: no version", info.Title)
+	}
+	profiles[info.Title] = info
+	return nil
+}
+
+// ReadProfiles reads a JSON array of profiles.
+func ReadProfiles(r io.Reader) ([]RomInfo, error) {
+	var infos []RomInfo
+	err := json.NewDecoder(r).Decode(&infos)
+	if err != nil {
+		return nil, err
+	}
+	return infos, nil
+}
+
+// A tableRange is a table or other piece of data checked by Validate.
+type tableRange struct {
+	name string
+	off  int64
+	size int64
+}
+
+// Validate checks that every table in info lies within a ROM of the given
+// size, and that the tables needed for ripping Pokémon are present.
+func (info *RomInfo) Validate(size int64) error {
+	if info.StatsOffset == 0 || info.PaletteOffset == 0 || info.SpriteOffset == 0 {
+		return fmt.Errorf("profile %s: missing stats, palette, or sprite offset", info.Title)
+	}
+	var tables = []tableRange{
+		{"stats", info.StatsOffset, statsSize * MaxPokemon},
+		{"palette", info.PaletteOffset, paletteTableSize},
+		{"sprite", info.SpriteOffset, 3 * 2 * MaxPokemon},
+		{"unown sprite", info.UnownSpriteOffset, 3 * 2 * int64(len(UnownForms))},
+		{"trainer", info.TrainerOffset, 3 * MaxTrainer},
+		{"trainer palette", info.TrainerPaletteOffset, 4 * MaxTrainer},
+		{"anim", info.AnimOffset, 2 * MaxPokemon},
+		{"extra", info.ExtraOffset, 2 * MaxPokemon},
+		{"frames", info.FramesOffset, 2 * MaxPokemon},
+		{"bitmaps", info.BitmapsOffset, 2 * MaxPokemon},
+		{"unown anim", info.UnownAnimOffset, 2 * int64(len(UnownForms))},
+		{"unown extra", info.UnownExtraOffset, 2 * int64(len(UnownForms))},
+		{"unown frames", info.UnownFramesOffset, 2 * int64(len(UnownForms))},
+		{"unown bitmaps", info.UnownBitmapsOffset, 2 * int64(len(UnownForms))},
+		{"menu icon", info.MenuIconsOffset, MaxPokemon},
+		{"icon", info.IconPointersOffset, 2},
+		{"icon bank", info.IconBank * bankSize, bankSize},
+		{"footprint", info.FootprintOffset, 256 * 32},
+		{"overworld", info.OverworldOffset, overworldEntrySize * int64(info.NumOverworld)},
+		{"pokemon name", info.PokemonNamesOffset, pokemonNameSize * MaxPokemon},
+		{"trainer class name", info.TrainerClassNamesOffset, 1},
+	}
+	for name, pic := range info.Backpics {
+		size := int64(pic.Width * pic.Height * 16)
+		if pic.Compressed {
+			size = 1
+		}
+		tables = append(tables,
+			tableRange{name + " backpic", pic.Offset, size},
+			tableRange{name + " backpic palette", pic.PaletteOffset, 4})
+	}
+	for _, t := range tables {
+		if t.off == 0 {
+			continue
+		}
+		if t.off < 0 || t.off+t.size > size {
+			return fmt.Errorf("profile %s: %s table at %#x doesn't fit in ROM of size %#x", info.Title, t.name, t.off, size)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package sprites
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestProfiles(t *testing.T) {
+	const title = "TESTHACK"
+	defer delete(profiles, title)
+
+	rom := make([]byte, 0x10000)
+	copy(rom[0x134:], title)
+	for _, tt := range []struct {
+		name string
+		json string
+		ok   bool
+	}{
+		{"past the end", `[{"title":"TESTHACK","version":"hack","stats_offset":4096,"palette_offset":8192,"sprite_offset":65000}]`, false},
+		{"missing sprite offset", `[{"title":"TESTHACK","version":"hack","stats_offset":4096,"palette_offset":8192}]`, false},
+		{"valid", `[{"title":"TESTHACK","version":"hack","stats_offset":4096,"palette_offset":8192,"sprite_offset":16384}]`, true},
+	} {
+		infos, err := ReadProfiles(strings.NewReader(tt.json))
+		if err != nil || len(infos) != 1 {
+			t.Fatalf("%s: got %d profiles, %v", tt.name, len(infos), err)
+		}
+		if err := RegisterProfile(infos[0]); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if err := infos[0].Validate(int64(len(rom))); (err == nil) != tt.ok {
+			t.Errorf("%s: Validate: got %v", tt.name, err)
+		}
+		rip, err := NewRipper(bytes.NewReader(rom))
+		if !tt.ok {
+			if err == nil {
+				t.Errorf("%s: NewRipper accepted a bad profile", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if info := rip.Profile(); info.Version != "hack" || info.SpriteOffset != 16384 {
+			t.Errorf("%s: NewRipper didn't use the profile: got %+v", tt.name, info)
+		}
+	}
+
+	if err := RegisterProfile(RomInfo{Version: "hack"}); err == nil {
+		t.Error("RegisterProfile accepted a profile with no title")
+	}
+}
@@ -0,0 +1,342 @@
+package rby
+
+import (
+	"errors"
+	"image/color"
+)
+
+/*
+
+When a game without GBC support is played on a GBC, the boot ROM colors it
+with one of a fixed set of palettes. For games published by Nintendo, it
+adds up the bytes of the title in the header and looks the sum up in a
+table. Some sums are shared by more than one game, so for those the fourth
+letter of the title is checked too. Everything else gets the default.
+
+The player can also pick a palette by holding a direction and optionally A
+or B while the GBC logo is shown. There are twelve of these.
+
+A palette is chosen for the background and for each of the two sprite
+palettes. The pics are drawn as part of the background.
+
+The tables below are those in the boot ROM.
+
+*/
+
+var ErrNoSuchPalette = errors.New("no such palette")
+
+// CompatPalettes are the palettes the GBC uses for a game without GBC
+// support.
+type CompatPalettes struct {
+	BG   color.Palette
+	OBJ0 color.Palette
+	OBJ1 color.Palette
+}
+
+// The colors of the palettes, four at a time. Palettes are usually
+// aligned, but a few of the combinations start in the middle of one.
+var compatColors = []RGB15{
+	0x7FFF, 0x32BF, 0x00D0, 0x0000, // 0
+	0x639F, 0x4279, 0x15B0, 0x04CB, // 1
+	0x7FFF, 0x6E31, 0x454A, 0x0000, // 2
+	0x7FFF, 0x1BEF, 0x0200, 0x0000, // 3
+	0x7FFF, 0x421F, 0x1CF2, 0x0000, // 4
+	0x7FFF, 0x5294, 0x294A, 0x0000, // 5
+	0x7FFF, 0x03FF, 0x012F, 0x0000, // 6
+	0x7FFF, 0x03EF, 0x01D6, 0x0000, // 7
+	0x7FFF, 0x42B5, 0x3DC8, 0x0000, // 8
+	0x7E74, 0x03FF, 0x0180, 0x0000, // 9
+	0x67FF, 0x77AC, 0x1A13, 0x2D6B, // 10
+	0x7ED6, 0x4BFF, 0x2175, 0x0000, // 11
+	0x53FF, 0x4A5F, 0x7E52, 0x0000, // 12
+	0x4FFF, 0x7ED2, 0x3A4C, 0x1CE0, // 13
+	0x03ED, 0x7FFF, 0x255F, 0x0000, // 14
+	0x036A, 0x021F, 0x03FF, 0x7FFF, // 15
+	0x7FFF, 0x01DF, 0x0112, 0x0000, // 16
+	0x231F, 0x035F, 0x00F2, 0x0009, // 17
+	0x7FFF, 0x03EA, 0x011F, 0x0000, // 18
+	0x299F, 0x001A, 0x000C, 0x0000, // 19
+	0x7FFF, 0x027F, 0x001F, 0x0000, // 20
+	0x7FFF, 0x03E0, 0x0206, 0x0120, // 21
+	0x7FFF, 0x7EEB, 0x001F, 0x7C00, // 22
+	0x7FFF, 0x3FFF, 0x7E00, 0x001F, // 23
+	0x7FFF, 0x03FF, 0x001F, 0x0000, // 24
+	0x03FF, 0x001F, 0x000C, 0x0000, // 25
+	0x7FFF, 0x033F, 0x0193, 0x0000, // 26
+	0x0000, 0x4200, 0x037F, 0x7FFF, // 27
+	0x7FFF, 0x7E8C, 0x7C00, 0x0000, // 28
+	0x7FFF, 0x1BEF, 0x6180, 0x0000, // 29
+}
+
+// Offsets into compatColors of the OBJ0, OBJ1, and BG palettes.
+type compatCombination [3]int
+
+func comb(obj0, obj1, bg int) compatCombination {
+	return compatCombination{obj0 * 4, obj1 * 4, bg * 4}
+}
+
+var compatCombinations = []compatCombination{
+	comb(4, 4, 29),             // 0
+	comb(18, 18, 18),           // 1
+	comb(20, 20, 20),           // 2
+	comb(24, 24, 24),           // 3
+	comb(9, 9, 9),              // 4
+	comb(0, 0, 0),              // 5
+	comb(27, 27, 27),           // 6
+	comb(5, 5, 5),              // 7
+	comb(12, 12, 12),           // 8
+	comb(26, 26, 26),           // 9
+	comb(16, 8, 8),             // 10
+	comb(4, 28, 28),            // 11
+	comb(4, 2, 2),              // 12
+	comb(3, 4, 4),              // 13
+	comb(4, 29, 29),            // 14
+	comb(28, 4, 28),            // 15
+	comb(2, 17, 2),             // 16
+	comb(16, 16, 8),            // 17
+	comb(4, 4, 7),              // 18
+	comb(4, 4, 18),             // 19
+	comb(4, 4, 20),             // 20
+	comb(19, 19, 9),            // 21
+	{4*4 - 1, 4*4 - 1, 11 * 4}, // 22
+	comb(17, 17, 2),            // 23
+	comb(4, 4, 2),              // 24
+	comb(4, 4, 3),              // 25
+	comb(28, 28, 0),            // 26
+	comb(3, 3, 0),              // 27
+	comb(0, 0, 1),              // 28
+	comb(18, 22, 18),           // 29
+	comb(20, 22, 20),           // 30
+	comb(24, 22, 24),           // 31
+	comb(16, 22, 8),            // 32
+	comb(17, 4, 13),            // 33
+	{28*4 - 1, 0 * 4, 14 * 4},  // 34
+	{28*4 - 1, 4 * 4, 15 * 4},  // 35
+	comb(19, 22, 9),            // 36
+	comb(16, 28, 10),           // 37
+	comb(4, 23, 28),            // 38
+	comb(17, 22, 2),            // 39
+	comb(4, 0, 2),              // 40
+	comb(4, 28, 3),             // 41
+	comb(28, 3, 0),             // 42
+	comb(3, 28, 4),             // 43
+	comb(21, 28, 4),            // 44
+	comb(3, 28, 0),             // 45
+	comb(25, 3, 28),            // 46
+	comb(0, 28, 8),             // 47
+	comb(4, 3, 28),             // 48
+	comb(28, 3, 6),             // 49
+	comb(4, 28, 29),            // 50
+}
+
+// The combination chosen by each button press, in the order ButtonNames
+// lists them.
+var buttonCombinations = []struct {
+	name  string
+	combo int
+}{
+	{"up", 5},
+	{"up+a", 43},
+	{"up+b", 28},
+	{"left", 48},
+	{"left+a", 40},
+	{"left+b", 7},
+	{"down", 8},
+	{"down+a", 3},
+	{"down+b", 49},
+	{"right", 1},
+	{"right+a", 0},
+	{"right+b", 6},
+}
+
+// Title checksums, and the combination used for each. The checksums from
+// index 65 on are shared, and are told apart by the fourth letter of the
+// title.
+var titleChecksums = []struct {
+	sum   byte
+	combo int
+}{
+	{0x00, 0},  // default
+	{0x88, 4},  // ALLEY WAY
+	{0x16, 5},  // YAKUMAN
+	{0x36, 35}, // BASEBALL
+	{0xD1, 34}, // TENNIS
+	{0xDB, 3},  // TETRIS
+	{0xF2, 31}, // QIX
+	{0x3C, 15}, // DR.MARIO
+	{0x8C, 10}, // RADARMISSION
+	{0x92, 5},  // F1RACE
+	{0x3D, 19}, // YOSSY NO TAMAGO
+	{0x5C, 36},
+	{0x58, 7},  // X
+	{0xC9, 37}, // MARIOLAND2
+	{0x3E, 30}, // YOSSY NO COOKIE
+	{0x70, 44}, // ZELDA
+	{0x1D, 21},
+	{0x59, 32}, // SUPERMARIOLAND3
+	{0x69, 31}, // TETRIS FLASH
+	{0x19, 20}, // DONKEY KONG
+	{0x35, 5},  // MARIO'S PICROSS
+	{0xA8, 33},
+	{0x14, 13}, // POKEMON RED
+	{0xAA, 14}, // POKEMON GREEN
+	{0x75, 5},  // PICROSS 2
+	{0x95, 29}, // YOSSY NO PANEPON
+	{0x99, 5},  // KIRAKIRA KIDS
+	{0x34, 18}, // GAMEBOY GALLERY
+	{0x6F, 9},  // POCKETCAMERA
+	{0x15, 3},
+	{0xFF, 2},  // BALLOON KID
+	{0x97, 26}, // KINGOFTHEZOO
+	{0x4B, 25}, // DMG FOOTBALL
+	{0x90, 25}, // WORLD CUP
+	{0x17, 41}, // OTHELLO
+	{0x10, 42}, // SUPER RC PRO-AM
+	{0x39, 26}, // DYNABLASTER
+	{0xF7, 45}, // BOY AND BLOB GB2
+	{0xF6, 42}, // MEGAMAN
+	{0xA2, 45}, // STAR WARS-NOA
+	{0x49, 36}, // KIRBY DREAM LAND
+	{0x4E, 38}, // WAVERACE
+	{0x43, 26},
+	{0x68, 42}, // LOLO2
+	{0xE0, 30}, // YOSHI'S COOKIE
+	{0x8B, 41}, // MYSTIC QUEST
+	{0xF0, 34},
+	{0xCE, 34}, // TOPRANKINGTENNIS
+	{0x0C, 5},  // MANSELL
+	{0x29, 42}, // MEGAMAN3
+	{0xE8, 6},  // SPACE INVADERS
+	{0xB7, 5},  // GAME&WATCH
+	{0x86, 33}, // DONKEYKONGLAND95
+	{0x9A, 25}, // ASTEROIDS/MISCMD
+	{0x52, 42}, // STREET FIGHTER 2
+	{0x01, 42}, // DEFENDER/JOUST
+	{0x9D, 40}, // KILLERINSTINCT95
+	{0x71, 2},  // TETRIS BLAST
+	{0x9C, 16}, // PINOCCHIO
+	{0xBD, 25},
+	{0x5D, 42}, // BA.TOSHINDEN
+	{0x6D, 42}, // NETTOU KOF 95
+	{0x67, 5},
+	{0x3F, 0},  // TETRIS PLUS
+	{0x6B, 39}, // DONKEYKONGLAND 3
+
+	{0xB3, 36},
+	{0x46, 22}, // SUPER MARIOLAND
+	{0x28, 25}, // GOLF
+	{0xA5, 6},  // SOLARSTRIKER
+	{0xC6, 32}, // GBWARS
+	{0xD3, 12}, // KAERUNOTAMENI
+	{0x27, 36},
+	{0x61, 11}, // POKEMON BLUE
+	{0x18, 39}, // DONKEYKONGLAND
+	{0x66, 18}, // GAMEBOY GALLERY2
+	{0x6A, 39}, // DONKEYKONGLAND 2
+	{0xBF, 24}, // KID ICARUS
+	{0x0D, 31}, // TETRIS2
+	{0xF4, 50},
+	{0xB3, 17}, // MOGURANYA
+	{0x46, 46}, // METROID2
+	{0x28, 6},  // GALAGA&GALAXIAN
+	{0xA5, 27}, // BT2RAGNAROKWORLD
+	{0xC6, 0},  // KEN GRIFFEY JR
+	{0xD3, 47}, // WARIOLAND2
+	{0x27, 41}, // MAGNETIC SOCCER
+	{0x61, 41}, // VEGAS STAKES
+	{0x18, 0},
+	{0x66, 0},  // MILLI/CENTI/PEDE
+	{0x6A, 19}, // MARIO & YOSHI
+	{0xBF, 34}, // SOCCER
+	{0x0D, 23}, // POKEBOM
+	{0xF4, 18}, // G&W GALLERY
+	{0xB3, 29}, // TETRIS ATTACK
+}
+
+// The index of the first shared checksum, and the fourth letters of the
+// titles that share them.
+const (
+	firstSharedChecksum = 65
+	fourthLetters       = "BEFAARBEKEK R-URAR INAILICE R"
+)
+
+// Offsets into the cartridge header.
+const (
+	titleOffset       = 0x134
+	newLicenseeOffset = 0x144
+	oldLicenseeOffset = 0x14B
+	headerEnd         = 0x150
+)
+
+// TitlePalettes returns the palettes the GBC boot ROM picks for a game
+// without GBC support, as it would if no buttons were pressed. The header
+// must hold at least the first 0x150 bytes of the ROM.
+func TitlePalettes(header []byte) CompatPalettes {
+	return compatPalettes(compatCombinations[titleCombination(header)])
+}
+
+func titleCombination(header []byte) int {
+	if len(header) < headerEnd {
+		return 0
+	}
+	// Only games published by Nintendo are looked up.
+	old := header[oldLicenseeOffset]
+	if old != 0x01 && !(old == 0x33 && string(header[newLicenseeOffset:newLicenseeOffset+2]) == "01") {
+		return 0
+	}
+	var sum byte
+	for _, c := range header[titleOffset : titleOffset+16] {
+		sum += c
+	}
+	for i, t := range titleChecksums {
+		if t.sum != sum {
+			continue
+		}
+		if i < firstSharedChecksum || header[titleOffset+3] == fourthLetters[i-firstSharedChecksum] {
+			return t.combo
+		}
+	}
+	return 0
+}
+
+// ButtonNames returns the names of the button presses that pick a palette,
+// like "up" or "left+b".
+func ButtonNames() []string {
+	var names []string
+	for _, b := range buttonCombinations {
+		names = append(names, b.name)
+	}
+	return names
+}
+
+// ButtonPalettes returns the palettes picked by holding the named buttons
+// at boot.
+func ButtonPalettes(name string) (CompatPalettes, error) {
+	for _, b := range buttonCombinations {
+		if b.name == name {
+			return compatPalettes(compatCombinations[b.combo]), nil
+		}
+	}
+	return CompatPalettes{}, ErrNoSuchPalette
+}
+
+// TitlePalettes returns the palettes the GBC boot ROM picks for the game.
+func (rip *Ripper) TitlePalettes() CompatPalettes {
+	return TitlePalettes(rip.rom)
+}
+
+func compatPalettes(c compatCombination) CompatPalettes {
+	return CompatPalettes{
+		OBJ0: compatPalette(c[0]),
+		OBJ1: compatPalette(c[1]),
+		BG:   compatPalette(c[2]),
+	}
+}
+
+func compatPalette(off int) color.Palette {
+	p := make(color.Palette, 4)
+	for i := range p {
+		p[i] = compatColors[off+i]
+	}
+	return p
+}
@@ -0,0 +1,217 @@
+package rby
+
+import (
+	"errors"
+	"image"
+)
+
+/*
+
+Encode undoes each step of Decode in reverse. The image is split into its
+low and high bit planes; the planes are delta-coded along each row and
+optionally xored together, according to the mode; and then each plane is
+written as alternating packets of literal bit pairs and runs of zero pairs.
+
+There are two choices to make: which plane is stored first, and which of the
+three modes to use. The best choice depends on the image, so Encode simply
+tries all six and keeps the shortest.
+
+*/
+
+var (
+	ErrBadSize       = errors.New("image dimensions must be a multiple of 8, at most 120")
+	ErrTooManyColors = errors.New("image uses more than four colors")
+)
+
+// The largest pic, in tiles, whose size fits in the header.
+const maxTiles = 15
+
+// The xor modes, as numbered by Decode.
+const (
+	modeDelta    = 0 // both planes are delta-coded
+	modeXor      = 1 // the first is delta-coded; the second is xored with it
+	modeDeltaXor = 2 // the second is xored with the first, then delta-coded
+	numModes     = 3
+	numOrders    = 2
+)
+
+// Encode compresses a Gen I pic. The image must be a multiple of 8 pixels
+// wide and tall, no more than 15 tiles in either direction, and use only the
+// first four palette entries. The result can be read back with Decode.
+func Encode(m *image.Paletted) ([]byte, error) {
+	var best []byte
+	for order := 0; order < numOrders; order++ {
+		for mode := 0; mode < numModes; mode++ {
+			data, err := encode(m, order, mode)
+			if err != nil {
+				return nil, err
+			}
+			if best == nil || len(data) < len(best) {
+				best = data
+			}
+		}
+	}
+	return best, nil
+}
+
+// Encode compresses m with the given plane order and mode.
+func encode(m *image.Paletted, order, mode int) ([]byte, error) {
+	w, h := m.Rect.Dx(), m.Rect.Dy()
+	if w%8 != 0 || h%8 != 0 || w == 0 || h == 0 || w/8 > maxTiles || h/8 > maxTiles {
+		return nil, ErrBadSize
+	}
+	width, height := w/8, h/8
+
+	// Split the image into bit planes, one byte per 8 pixels.
+	lo := make([]byte, width*h)
+	hi := make([]byte, width*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := m.Pix[m.PixOffset(m.Rect.Min.X+x, m.Rect.Min.Y+y)]
+			if c > 3 {
+				return nil, ErrTooManyColors
+			}
+			i := y*width + x/8
+			bit := uint(7 - x%8)
+			lo[i] |= c & 1 << bit
+			hi[i] |= c >> 1 << bit
+		}
+	}
+
+	s0, s1 := lo, hi
+	if order == 1 {
+		s0, s1 = hi, lo
+	}
+	switch mode {
+	case modeDelta:
+		s0 = xorRows(s0, width, height)
+		s1 = xorRows(s1, width, height)
+	case modeXor:
+		s1 = xorPlanes(s1, s0)
+		s0 = xorRows(s0, width, height)
+	case modeDeltaXor:
+		s1 = xorRows(xorPlanes(s1, s0), width, height)
+		s0 = xorRows(s0, width, height)
+	}
+
+	bw := &bitWriter{}
+	bw.WriteBits(4, uint32(width))
+	bw.WriteBits(4, uint32(height))
+	bw.WriteBits(1, uint32(order))
+	writePixels(bw, s0, width, height)
+	switch mode {
+	case modeDelta:
+		bw.WriteBits(1, 0)
+	case modeXor:
+		bw.WriteBits(2, 2)
+	case modeDeltaXor:
+		bw.WriteBits(2, 3)
+	}
+	writePixels(bw, s1, width, height)
+	return bw.Bytes(), nil
+}
+
+// XorRows is the inverse of unxor: it xors each pixel with the one before
+// it in the same row.
+func xorRows(b []uint8, width, height int) []uint8 {
+	out := make([]uint8, len(b))
+	for y := 0; y < height*8; y++ {
+		prev := uint8(0)
+		for x := 0; x < width; x++ {
+			i := y*width + x
+			out[i] = b[i] ^ b[i]>>1 ^ prev<<7
+			prev = b[i] & 1
+		}
+	}
+	return out
+}
+
+func xorPlanes(a, b []uint8) []uint8 {
+	out := make([]uint8, len(a))
+	for i := range a {
+		out[i] = a[i] ^ b[i]
+	}
+	return out
+}
+
+// WritePixels is the inverse of readPixels. The pixels are written in
+// columns of bit pairs, as alternating packets of nonzero pairs and runs
+// of zero pairs. A packet of pairs ends with a zero pair, except at the end.
+func writePixels(w *bitWriter, b []uint8, width, height int) {
+	pairs := make([]uint8, 0, len(b)*4)
+	for x := 0; x < width; x++ {
+		for shift := 6; shift >= 0; shift -= 2 {
+			for y := 0; y < height*8; y++ {
+				pairs = append(pairs, b[y*width+x]>>uint(shift)&3)
+			}
+		}
+	}
+
+	i := 0
+	if pairs[0] == 0 {
+		w.WriteBits(1, 0)
+	} else {
+		w.WriteBits(1, 1)
+		i = writeLiteral(w, pairs)
+	}
+	for i < len(pairs) {
+		n := 0
+		for i+n < len(pairs) && pairs[i+n] == 0 {
+			n++
+		}
+		encode16(w, uint16(n))
+		i += n
+		i += writeLiteral(w, pairs[i:])
+	}
+}
+
+// WriteLiteral writes nonzero pairs up to the next zero pair, and the zero
+// pair that ends the packet. It returns the number of nonzero pairs.
+func writeLiteral(w *bitWriter, pairs []uint8) int {
+	for i, p := range pairs {
+		if p == 0 {
+			w.WriteBits(2, 0)
+			return i
+		}
+		w.WriteBits(2, uint32(p))
+	}
+	return len(pairs)
+}
+
+// Encode16 is the inverse of decode16. It writes n-1 one bits and a zero to
+// give the length, then the low n bits of v+1.
+func encode16(w *bitWriter, v uint16) {
+	x := uint32(v) + 1
+	n := uint(0)
+	for x>>(n+1) != 0 {
+		n++
+	}
+	w.WriteBits(n-1, 1<<(n-1)-1)
+	w.WriteBits(1, 0)
+	w.WriteBits(n, x)
+}
+
+// BitWriter is a big-endian bit writer.
+type bitWriter struct {
+	buf   []byte
+	bits  uint32
+	count uint
+}
+
+// WriteBits writes the low n bits of v. N must be at most 24.
+func (bw *bitWriter) WriteBits(n uint, v uint32) {
+	bw.bits = bw.bits<<n | v&(1<<n-1)
+	bw.count += n
+	for bw.count >= 8 {
+		bw.count -= 8
+		bw.buf = append(bw.buf, byte(bw.bits>>bw.count))
+	}
+}
+
+// Bytes returns the bits written so far, padded with zeros to a whole byte.
+func (bw *bitWriter) Bytes() []byte {
+	if bw.count > 0 {
+		return append(bw.buf, byte(bw.bits<<(8-bw.count)))
+	}
+	return bw.buf
+}
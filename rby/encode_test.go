@@ -0,0 +1,78 @@
+package rby
+
+import (
+	"bytes"
+	"image"
+	"math/rand"
+	"testing"
+)
+
+func TestEncodeRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, size := range []image.Point{{1, 1}, {4, 4}, {5, 5}, {6, 6}, {7, 7}, {3, 9}, {15, 15}} {
+		for _, ncolors := range []int{1, 2, 4} {
+			m := image.NewPaletted(image.Rect(0, 0, size.X*8, size.Y*8), grayPalette)
+			// Runs of colors, so that there is something to compress.
+			for i := 0; i < len(m.Pix); {
+				c := uint8(rng.Intn(ncolors))
+				for n := rng.Intn(12); n >= 0 && i < len(m.Pix); n-- {
+					m.Pix[i] = c
+					i++
+				}
+			}
+			best, err := Encode(m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for order := 0; order < numOrders; order++ {
+				for mode := 0; mode < numModes; mode++ {
+					data, err := encode(m, order, mode)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if len(data) < len(best) {
+						t.Errorf("%dx%d, %d colors: order %d, mode %d is shorter than Encode", size.X, size.Y, ncolors, order, mode)
+					}
+					got, err := Decode(bytes.NewReader(data))
+					if err != nil {
+						t.Errorf("%dx%d, %d colors, order %d, mode %d: decode error: %v", size.X, size.Y, ncolors, order, mode, err)
+						continue
+					}
+					if got.Rect != m.Rect || !bytes.Equal(got.Pix, m.Pix) {
+						t.Errorf("%dx%d, %d colors, order %d, mode %d: image doesn't round-trip", size.X, size.Y, ncolors, order, mode)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestEncodeBlank(t *testing.T) {
+	m := image.NewPaletted(image.Rect(0, 0, 8, 8), grayPalette)
+	data, err := Encode(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The same as the hand-assembled pic in TestDecodeBlank.
+	want := []byte{0x11, 0x3C, 0x13, 0xC1}
+	if !bytes.Equal(data, want) {
+		t.Errorf("got % x, want % x", data, want)
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	for _, r := range []image.Rectangle{
+		image.Rect(0, 0, 0, 0),
+		image.Rect(0, 0, 12, 8),
+		image.Rect(0, 0, 128, 8),
+	} {
+		if _, err := Encode(image.NewPaletted(r, grayPalette)); err != ErrBadSize {
+			t.Errorf("%v: got error %v, want %v", r, err, ErrBadSize)
+		}
+	}
+	m := image.NewPaletted(image.Rect(0, 0, 8, 8), nil)
+	m.Pix[10] = 4
+	if _, err := Encode(m); err != ErrTooManyColors {
+		t.Errorf("got error %v, want %v", err, ErrTooManyColors)
+	}
+}
@@ -0,0 +1,155 @@
+package rby
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+)
+
+// The game identifies species by an internal index rather than by Pokédex
+// number. Only 190 of the 255 indexes belong to real Pokémon; the rest are
+// glitch species like MissingNo., whose Pokédex number is 0. The game looks
+// up their base stats anyway, one entry before Bulbasaur's modulo 256, and
+// decompresses whatever their pic pointers point at.
+
+const MaxIndex = 255
+
+// The largest pic the game has room for, in tiles.
+const maxPicSize = 7
+
+var (
+	ErrNoSuchIndex = errors.New("no such index")
+	ErrEmptyPic    = errors.New("pic has no tiles")
+	ErrPicTooLarge = errors.New("pic is larger than 7x7 tiles")
+	ErrPastBank    = errors.New("pic runs past the end of its bank")
+)
+
+// An IndexError records a problem with the pic for an internal index.
+type IndexError struct {
+	Index         int   // internal index
+	Offset        int64 // file offset of the pic
+	Width, Height int   // size in tiles, from the pic's header
+	Err           error
+}
+
+func (e *IndexError) Error() string {
+	return fmt.Sprintf("index %d: pic at %#x (%dx%d tiles): %s", e.Index, e.Offset, e.Width, e.Height, e.Err)
+}
+
+func (e *IndexError) Unwrap() error { return e.Err }
+
+// PokedexNumber returns the Pokédex number of the species with the given
+// internal index, or 0 if it is a glitch species.
+func (rip *Ripper) PokedexNumber(index int) int {
+	if 1 > index || index > MaxIndex {
+		return 0
+	}
+	// Past the end of the table, the game reads whatever comes next.
+	off := rip.dexOrderOffset + index - 1
+	if off >= len(rip.rom) {
+		return 0
+	}
+	return int(rip.rom[off])
+}
+
+// PokemonByIndex returns the front pic of the species with the given
+// internal index, from 1 to 255, including glitch species.
+//
+// Glitch pics are often not pics at all. If the pic's header gives a size
+// of more than 7x7 tiles, which would overflow the game's buffer, the pic
+// is returned along with an *IndexError. Other problems, such as a pic with
+// no tiles or one which runs past the end of its bank, are returned as an
+// *IndexError with no pic.
+func (rip *Ripper) PokemonByIndex(index int) (*image.Paletted, error) {
+	return rip.pokemonByIndex(index, false)
+}
+
+// PokemonBackByIndex is like PokemonByIndex, but returns the back pic.
+func (rip *Ripper) PokemonBackByIndex(index int) (*image.Paletted, error) {
+	return rip.pokemonByIndex(index, true)
+}
+
+func (rip *Ripper) pokemonByIndex(index int, back bool) (*image.Paletted, error) {
+	if 1 > index || index > MaxIndex {
+		return nil, ErrNoSuchIndex
+	}
+	number := rip.PokedexNumber(index)
+	if 1 <= number && number <= MaxPokemon {
+		if back {
+			return rip.PokemonBack(number)
+		}
+		return rip.Pokemon(number)
+	}
+
+	// The fossils and the ghost have their own pics, but no back pics.
+	// The game never reads their base stats for the front pic, so if the
+	// pic wasn't found there is nothing to fall back on.
+	if !back {
+		for name, i := range specialIndexes {
+			if i == index {
+				if _, ok := rip.pics[name]; !ok {
+					return nil, &IndexError{index, 0, 0, 0, ErrNoSuchSprite}
+				}
+				return rip.Pic(name)
+			}
+		}
+	}
+
+	// Read the pic pointer from the glitch species' base stats.
+	entry := rip.statsOffset + ((number-1)&0xFF)*baseStatsSize
+	ptrOff := entry + frontPointerOffset
+	if back {
+		ptrOff = entry + backPointerOffset
+	}
+	if ptrOff+2 > len(rip.rom) {
+		return nil, &IndexError{index, int64(ptrOff), 0, 0, io.ErrUnexpectedEOF}
+	}
+	off := farOffset(rip.getBank(index), rip.word(ptrOff))
+	if off >= int64(len(rip.rom)) {
+		return nil, &IndexError{index, off, 0, 0, io.ErrUnexpectedEOF}
+	}
+
+	w, h := int(rip.rom[off]>>4), int(rip.rom[off]&0xF)
+	if w == 0 || h == 0 {
+		return nil, &IndexError{index, off, w, h, ErrEmptyPic}
+	}
+	bankEnd := (off/bankSize + 1) * bankSize
+	if bankEnd > int64(len(rip.rom)) {
+		bankEnd = int64(len(rip.rom))
+	}
+	m, err := Decode(bytes.NewReader(rip.rom[off:bankEnd]))
+	if err == io.ErrUnexpectedEOF {
+		return nil, &IndexError{index, off, w, h, ErrPastBank}
+	} else if err != nil {
+		return nil, &IndexError{index, off, w, h, err}
+	}
+	m.Palette = rip.indexPalette(number)
+	if w > maxPicSize || h > maxPicSize {
+		return m, &IndexError{index, off, w, h, ErrPicTooLarge}
+	}
+	return m, nil
+}
+
+// IndexPalette returns the palette for a Pokédex number, which for glitch
+// species may lie past the end of the palette map. It falls back to gray if
+// the palette doesn't exist.
+func (rip *Ripper) indexPalette(number int) color.Palette {
+	var pal color.Palette
+	if off := rip.paletteMapOffset + number; off < len(rip.rom) {
+		pal = rip.defaultPalette(rip.rom[off])
+	}
+	if pal == nil {
+		pal = append(color.Palette(nil), grayPalette...)
+	}
+	return pal
+}
+
+// The internal indexes of the pics named by PicNames.
+var specialIndexes = map[string]int{
+	"fossil-kabutops":   fossilKabutopsIndex,
+	"fossil-aerodactyl": fossilAerodactylIndex,
+	"ghost":             ghostIndex,
+}
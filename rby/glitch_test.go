@@ -0,0 +1,55 @@
+package rby
+
+import (
+	"errors"
+	"image"
+	"testing"
+)
+
+func TestPokemonByIndex(t *testing.T) {
+	rom := make([]byte, 0x30000)
+	rip := &Ripper{
+		rom:              rom,
+		getBank:          getBankRBY,
+		dexOrderOffset:   0x100, // all glitch species
+		statsOffset:      0x1000,
+		paletteMapOffset: 0x2000,
+	}
+	// Every glitch species uses the entry before Bulbasaur's, modulo 256.
+	entry := rip.statsOffset + 255*baseStatsSize
+	rom[entry+frontPointerOffset+1] = 0x40
+
+	// Index 1 is in bank 9, and has a 9x9 pic.
+	m := image.NewPaletted(image.Rect(0, 0, 72, 72), grayPalette)
+	m.Pix[0] = 3
+	data, err := Encode(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copy(rom[0x24000:], data)
+
+	got, err := rip.PokemonByIndex(1)
+	var ie *IndexError
+	if !errors.As(err, &ie) || ie.Err != ErrPicTooLarge || ie.Width != 9 || ie.Height != 9 {
+		t.Errorf("index 1: got error %v, want %v", err, ErrPicTooLarge)
+	}
+	if got == nil || got.Rect != m.Rect || got.Pix[0] != 3 {
+		t.Errorf("index 1: didn't get the pic")
+	}
+
+	// Index 0x20 is in bank 0xA, where there is nothing.
+	got, err = rip.PokemonByIndex(0x20)
+	if !errors.As(err, &ie) || ie.Err != ErrEmptyPic || got != nil {
+		t.Errorf("index 0x20: got %v, %v, want error %v", got, err, ErrEmptyPic)
+	}
+
+	// The ghost's pic wasn't found.
+	got, err = rip.PokemonByIndex(ghostIndex)
+	if !errors.As(err, &ie) || ie.Err != ErrNoSuchSprite || got != nil {
+		t.Errorf("ghost: got %v, %v, want error %v", got, err, ErrNoSuchSprite)
+	}
+
+	if _, err := rip.PokemonByIndex(256); err != ErrNoSuchIndex {
+		t.Errorf("index 256: got error %v, want %v", err, ErrNoSuchIndex)
+	}
+}
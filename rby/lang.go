@@ -0,0 +1,68 @@
+package rby
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+)
+
+// The English, French, German, Italian, and Spanish releases all have the
+// same header titles and destination code, so the language is found by
+// other means: first by looking the ROM up in a table of known checksums,
+// and failing that by searching it for text that only appears in one
+// language. The text search also covers patched and hacked ROMs.
+
+// SHA-1 checksums of known ROMs, and their languages. Only checksums that
+// have been checked against real dumps belong here; a release that isn't
+// listed is still found by the text search.
+var languageChecksums = map[string]string{
+	"ea9bcae617fdf159b045185467ae58b2e4a48b9a": "en", // Red
+	"d7037c83e1ae5b39bde3c30787637ba1d4c48ce2": "en", // Blue
+	"cc7d03262ebfaf2f06772c1a480c7d9d5f4a38e1": "en", // Yellow
+}
+
+// The name of the Fighting type, which follows the name of the Normal type
+// in the type name table. Each is surrounded by string terminators, which
+// keeps the shorter names from matching in the middle of other text.
+var languageText = []struct {
+	lang string
+	text string
+}{
+	{"en", "@FIGHTING@"},
+	{"fr", "@COMBAT@"},
+	{"de", "@KAMPF@"},
+	{"it", "@LOTTA@"},
+	{"es", "@LUCHA@"},
+}
+
+// DetectLanguage returns the language of a western ROM, or en if it can't
+// be determined.
+func detectLanguage(rom []byte) string {
+	sum := sha1.Sum(rom)
+	if lang, ok := languageChecksums[hex.EncodeToString(sum[:])]; ok {
+		return lang
+	}
+	for _, t := range languageText {
+		if bytes.Contains(rom, encodeText(t.text)) {
+			return t.lang
+		}
+	}
+	return "en"
+}
+
+// EncodeText converts uppercase ASCII text to the game's character set.
+// @ is the string terminator. Other characters are not supported.
+func encodeText(s string) []byte {
+	b := make([]byte, len(s))
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '@':
+			b[i] = 0x50
+		case 'A' <= c && c <= 'Z':
+			b[i] = 0x80 + c - 'A'
+		default:
+			panic("rby: unsupported character in " + s)
+		}
+	}
+	return b
+}
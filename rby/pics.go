@@ -0,0 +1,309 @@
+package rby
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"sort"
+)
+
+// Pics other than the Pokémon's are found by searching for the code and
+// tables that refer to them. Anything that can't be found is left out.
+
+const MaxTrainer = 47
+
+var (
+	ErrNoSuchTrainer = errors.New("no such trainer")
+	ErrNoSuchSprite  = errors.New("no such sprite")
+)
+
+// A pattern is a byte string to search for. Wild entries match any byte.
+type pattern []int
+
+const wild = -1
+
+var (
+	// The base reward money of the first six trainer classes, in BCD,
+	// following each pic pointer in the trainer pic and money table.
+	trainerMoneyPattern = pattern{
+		wild, wild, 0x00, 0x15, 0x00, // youngster, 1500
+		wild, wild, 0x00, 0x10, 0x00, // bug catcher, 1000
+		wild, wild, 0x00, 0x15, 0x00, // lass, 1500
+		wild, wild, 0x00, 0x30, 0x00, // sailor, 3000
+		wild, wild, 0x00, 0x20, 0x00, // jr. trainer♂, 2000
+		wild, wild, 0x00, 0x20, 0x00, // jr. trainer♀, 2000
+	}
+
+	// In LoadPlayerBackPic:
+	//	dec a
+	//	ld de, RedPicBack
+	//	jr nz, .next
+	//	ld de, OldManPicBack
+	//	.next
+	//	ld a, BANK(RedPicBack)
+	playerBackPattern = pattern{0x3D, 0x11, wild, wild, 0x20, 0x03, 0x11, wild, wild, 0x3E, wild}
+
+	// In OakSpeech:
+	//	ld de, RedPicFront
+	//	lb bc, BANK(RedPicFront), $00
+	//	call IntroDisplayPicCenteredOrUpperRight
+	// The same code also shows Oak and the rival, whose pics are in the
+	// trainer table.
+	introPicPattern = pattern{0x11, wild, wild, 0x01, 0x00, wild, 0xCD}
+
+	// In InitWildBattle, for the ghost in Pokémon Tower:
+	//	ld a, $66
+	//	ld [hli], a
+	//	ld bc, GhostPic
+	ghostPattern = pattern{0x3E, 0x66, 0x22, 0x01, wild, wild}
+
+	// In GetMonHeader:
+	//	ld de, FossilKabutopsPic
+	//	ld b, $66
+	//	cp FOSSIL_KABUTOPS
+	kabutopsPattern = pattern{0x11, wild, wild, 0x06, 0x66, 0xFE, 0xB6}
+	//	ld de, FossilAerodactylPic
+	//	ld b, $77
+	//	cp FOSSIL_AERODACTYL
+	aerodactylPattern = pattern{0x11, wild, wild, 0x06, 0x77, 0xFE, 0xB7}
+
+	// In UncompressMonSprite:
+	//	cp FOSSIL_KABUTOPS
+	//	ld a, BANK(FossilKabutopsPic)
+	//	jr z, .GotBank
+	kabutopsBankPattern = pattern{0xFE, 0xB6, 0x3E, wild, 0x28}
+)
+
+// Internal indexes of the fake species used for the ghost and fossils.
+const (
+	fossilKabutopsIndex   = 0xB6
+	fossilAerodactylIndex = 0xB7
+	ghostIndex            = 0xB8
+)
+
+const trainerEntrySize = 5
+
+// Find returns the offset of the first match of p in b at or after start,
+// or -1.
+func (p pattern) find(b []byte, start int) int {
+	for i := start; i+len(p) <= len(b); i++ {
+		ok := true
+		for j, c := range p {
+			if c >= 0 && b[i+j] != byte(c) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return i
+		}
+	}
+	return -1
+}
+
+// A specialPic is a pic which isn't in a table.
+type specialPic struct {
+	offset  int64
+	palette byte // index into the palette tables
+}
+
+// FarOffset converts a pointer into the given bank to a file offset.
+func farOffset(bank int, ptr uint16) int64 {
+	if ptr < 0x4000 {
+		return int64(ptr)
+	}
+	return int64(bank-1)<<14 + int64(ptr)
+}
+
+// Word reads a little-endian pointer at off.
+func (rip *Ripper) word(off int) uint16 {
+	return binary.LittleEndian.Uint16(rip.rom[off:])
+}
+
+// HasPic reports whether there is a compressed pic of w by h tiles at off.
+func (rip *Ripper) hasPic(off int64, w, h int) bool {
+	return off >= 0 && off < int64(len(rip.rom)) && rip.rom[off] == byte(w<<4|h)
+}
+
+// FindPics locates the trainer pic table and the special pics.
+func (rip *Ripper) findPics() {
+	rom := rip.rom
+	rip.pics = make(map[string]specialPic)
+	rip.backpics = make(map[string]specialPic)
+
+	// Look for a bank in which every trainer pointer points at a 7x7 pic.
+	if pos := trainerMoneyPattern.find(rom, 0); pos >= 0 && pos+MaxTrainer*trainerEntrySize <= len(rom) {
+	banks:
+		for bank := 1; int64(bank)<<14 < int64(len(rom)); bank++ {
+			for i := 0; i < MaxTrainer; i++ {
+				off := farOffset(bank, rip.word(pos+i*trainerEntrySize))
+				if !rip.hasPic(off, 7, 7) {
+					continue banks
+				}
+			}
+			rip.trainerTable = int64(pos)
+			rip.trainerBank = bank
+			break
+		}
+	}
+	isTrainer := func(off int64) bool {
+		for i := 0; i < MaxTrainer; i++ {
+			if t, err := rip.trainerOffset(i + 1); err == nil && t == off {
+				return true
+			}
+		}
+		return false
+	}
+
+	if pos := playerBackPattern.find(rom, 0); pos >= 0 {
+		bank := int(rom[pos+10])
+		for _, p := range []struct {
+			name string
+			ptr  int
+		}{{"player", pos + 2}, {"oldman", pos + 7}} {
+			off := farOffset(bank, rip.word(p.ptr))
+			if rip.hasPic(off, 4, 4) {
+				rip.backpics[p.name] = specialPic{off, rip.trainerPalette}
+			}
+		}
+	}
+
+	if rip.trainerTable != 0 {
+		for pos := introPicPattern.find(rom, 0); pos >= 0; pos = introPicPattern.find(rom, pos+1) {
+			off := farOffset(int(rom[pos+5]), rip.word(pos+1))
+			if rip.hasPic(off, 7, 7) && !isTrainer(off) {
+				rip.pics["player"] = specialPic{off, rip.trainerPalette}
+				break
+			}
+		}
+	}
+
+	// The game has no palette of its own for the ghost. SetPal_Battle
+	// looks up the enemy's real species, so each ghost is shown in the
+	// palette of the Pokémon behind it. Gastly is the one met most often in
+	// Pokémon Tower, so its palette stands in for the rest.
+	if pos := ghostPattern.find(rom, 0); pos >= 0 {
+		off := farOffset(rip.getBank(ghostIndex), rip.word(pos+4))
+		if rip.hasPic(off, 6, 6) {
+			rip.pics["ghost"] = specialPic{off, rip.spritePalette[92-1]}
+		}
+	}
+	if pos, bpos := kabutopsPattern.find(rom, 0), kabutopsBankPattern.find(rom, 0); pos >= 0 && bpos >= 0 {
+		off := farOffset(int(rom[bpos+3]), rip.word(pos+1))
+		if rip.hasPic(off, 6, 6) {
+			rip.pics["fossil-kabutops"] = specialPic{off, rip.spritePalette[141-1]}
+		}
+	}
+	if pos := aerodactylPattern.find(rom, 0); pos >= 0 {
+		off := farOffset(rip.getBank(fossilAerodactylIndex), rip.word(pos+1))
+		if rip.hasPic(off, 7, 7) {
+			rip.pics["fossil-aerodactyl"] = specialPic{off, rip.spritePalette[142-1]}
+		}
+	}
+}
+
+// HasTrainers reports whether the trainer pics were found.
+func (rip *Ripper) HasTrainers() bool {
+	return rip.trainerTable != 0
+}
+
+func (rip *Ripper) trainerOffset(number int) (int64, error) {
+	if 1 > number || number > MaxTrainer || rip.trainerTable == 0 {
+		return 0, ErrNoSuchTrainer
+	}
+	ptr := rip.word(int(rip.trainerTable) + (number-1)*trainerEntrySize)
+	return farOffset(rip.trainerBank, ptr), nil
+}
+
+// Trainer returns the pic of a trainer class, numbered from 1 as in the
+// game. The player's rival is classes 25, 42, and 43. Trainers are shown
+// in the same palette as each other.
+func (rip *Ripper) Trainer(number int) (*image.Paletted, error) {
+	off, err := rip.trainerOffset(number)
+	if err != nil {
+		return nil, err
+	}
+	return rip.decodeSpecial(specialPic{off, rip.trainerPalette})
+}
+
+// TrainerPaletteFor returns the palette a trainer is shown in on a
+// particular system, as for PokemonPaletteFor.
+func (rip *Ripper) TrainerPaletteFor(number int, system string) (color.Palette, error) {
+	if _, err := rip.trainerOffset(number); err != nil {
+		return nil, err
+	}
+	return rip.paletteFor(rip.trainerPalette, system)
+}
+
+// PicNames returns the names of the other pics found in the ROM, in sorted
+// order. They can include "player", for the player's front pic; "ghost",
+// for the unidentified ghosts in Pokémon Tower; and "fossil-kabutops" and
+// "fossil-aerodactyl", for the fossils in the Pewter Museum.
+func (rip *Ripper) PicNames() []string {
+	return sortedNames(rip.pics)
+}
+
+// Pic returns one of the pics named by PicNames.
+func (rip *Ripper) Pic(name string) (*image.Paletted, error) {
+	pic, ok := rip.pics[name]
+	if !ok {
+		return nil, ErrNoSuchSprite
+	}
+	return rip.decodeSpecial(pic)
+}
+
+// PicPaletteFor returns the palette one of the pics named by PicNames is
+// shown in on a particular system, as for PokemonPaletteFor.
+func (rip *Ripper) PicPaletteFor(name, system string) (color.Palette, error) {
+	pic, ok := rip.pics[name]
+	if !ok {
+		return nil, ErrNoSuchSprite
+	}
+	return rip.paletteFor(pic.palette, system)
+}
+
+// BackpicNames returns the names of the back pics found in the ROM, in
+// sorted order: "player", and "oldman" for the old man who shows the
+// player how to catch Pokémon.
+func (rip *Ripper) BackpicNames() []string {
+	return sortedNames(rip.backpics)
+}
+
+// Backpic returns one of the back pics named by BackpicNames.
+func (rip *Ripper) Backpic(name string) (*image.Paletted, error) {
+	pic, ok := rip.backpics[name]
+	if !ok {
+		return nil, ErrNoSuchSprite
+	}
+	return rip.decodeSpecial(pic)
+}
+
+// BackpicPaletteFor returns the palette one of the back pics named by
+// BackpicNames is shown in on a particular system, as for
+// PokemonPaletteFor.
+func (rip *Ripper) BackpicPaletteFor(name, system string) (color.Palette, error) {
+	pic, ok := rip.backpics[name]
+	if !ok {
+		return nil, ErrNoSuchSprite
+	}
+	return rip.paletteFor(pic.palette, system)
+}
+
+func sortedNames(pics map[string]specialPic) []string {
+	var names []string
+	for name := range pics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (rip *Ripper) decodeSpecial(pic specialPic) (*image.Paletted, error) {
+	m, err := rip.decode(pic.offset)
+	if err != nil {
+		return nil, err
+	}
+	m.Palette = rip.defaultPalette(pic.palette)
+	return m, nil
+}
@@ -0,0 +1,101 @@
+package rby
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestFindPics(t *testing.T) {
+	rom := make([]byte, 0x40000)
+	rip := &Ripper{rom: rom, getBank: getBankRBY, trainerPalette: 1}
+	for i := 0; i < 32; i++ {
+		rip.sgbPalettes = append(rip.sgbPalettes, color.Palette{color.Gray{uint8(i)}})
+	}
+	rip.spritePalette[92-1] = 2  // gastly
+	rip.spritePalette[141-1] = 3 // kabutops
+	rip.spritePalette[142-1] = 4 // aerodactyl
+
+	// Every trainer's pic is at 1:5000. Only the size of a pic is checked.
+	money := []byte{0x15, 0x10, 0x15, 0x30, 0x20, 0x20}
+	for i := 0; i < MaxTrainer; i++ {
+		e := rom[0x1000+i*trainerEntrySize:]
+		e[0], e[1] = 0x00, 0x50
+		if i < len(money) {
+			e[3] = money[i]
+		}
+	}
+	rom[0x5000] = 0x77
+
+	for off, code := range map[int][]byte{
+		0x2000: {0x3D, 0x11, 0x00, 0x41, 0x20, 0x03, 0x11, 0x00, 0x42, 0x3E, 0x02}, // player and old man backs, 2:4100 and 2:4200
+		0x2100: {0x11, 0x00, 0x43, 0x01, 0x00, 0x02, 0xCD},                         // player, 2:4300
+		0x2200: {0x3E, 0x66, 0x22, 0x01, 0x00, 0x40},                               // ghost, D:4000
+		0x2300: {0x11, 0x00, 0x40, 0x06, 0x66, 0xFE, 0xB6},                         // kabutops fossil, B:4000
+		0x2400: {0xFE, 0xB6, 0x3E, 0x0B, 0x28},                                     // BANK(FossilKabutopsPic)
+		0x2500: {0x11, 0x00, 0x41, 0x06, 0x77, 0xFE, 0xB7},                         // aerodactyl fossil, D:4100
+	} {
+		copy(rom[off:], code)
+	}
+	sizes := map[int64]byte{
+		0x8100:  0x44,
+		0x8200:  0x44,
+		0x8300:  0x77,
+		0x34000: 0x66,
+		0x2C000: 0x66,
+		0x34100: 0x77,
+	}
+	for off, size := range sizes {
+		rom[off] = size
+	}
+
+	rip.findPics()
+	if !rip.HasTrainers() || rip.trainerBank != 1 {
+		t.Errorf("trainer table: got %#x in bank %d, want 0x1000 in bank 1", rip.trainerTable, rip.trainerBank)
+	}
+	for _, tt := range []struct {
+		back    bool
+		name    string
+		want    int64
+		palette uint8
+	}{
+		{true, "player", 0x8100, 1},
+		{true, "oldman", 0x8200, 1},
+		{false, "player", 0x8300, 1},
+		{false, "ghost", 0x34000, 2},
+		{false, "fossil-kabutops", 0x2C000, 3},
+		{false, "fossil-aerodactyl", 0x34100, 4},
+	} {
+		pics, paletteFor := rip.pics, rip.PicPaletteFor
+		if tt.back {
+			pics, paletteFor = rip.backpics, rip.BackpicPaletteFor
+		}
+		pic, ok := pics[tt.name]
+		if !ok {
+			t.Errorf("%s: not found", tt.name)
+			continue
+		}
+		if pic.offset != tt.want {
+			t.Errorf("%s: got %#x, want %#x", tt.name, pic.offset, tt.want)
+		}
+		pal, err := paletteFor(tt.name, "sgb")
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		} else if pal[0] != (color.Gray{tt.palette}) {
+			t.Errorf("%s: got palette %v, want sgb palette %d", tt.name, pal, tt.palette)
+		}
+		if _, err := paletteFor(tt.name, "gbc"); err != ErrNoSuchSystem {
+			t.Errorf("%s: got %v for gbc, want %v", tt.name, err, ErrNoSuchSystem)
+		}
+	}
+
+	pal, err := rip.TrainerPaletteFor(1, "sgb")
+	if err != nil || pal[0] != (color.Gray{1}) {
+		t.Errorf("trainer: got palette %v, %v, want sgb palette 1", pal, err)
+	}
+	if _, err := rip.TrainerPaletteFor(MaxTrainer+1, "sgb"); err != ErrNoSuchTrainer {
+		t.Errorf("trainer %d: got %v, want %v", MaxTrainer+1, err, ErrNoSuchTrainer)
+	}
+	if _, err := rip.PicPaletteFor("missingno", "sgb"); err != ErrNoSuchSprite {
+		t.Errorf("missingno: got %v, want %v", err, ErrNoSuchSprite)
+	}
+}
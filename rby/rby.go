@@ -0,0 +1,420 @@
+// Package rby rips Pokémon pics from Pokémon Red, Green, Blue, and Yellow.
+package rby
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+const MaxPokemon = 151
+
+// Gen I ROMs are at most 1 MiB.
+const maxRomSize = 1 << 20
+
+var (
+	ErrNoSuchPokemon = errors.New("no such Pokémon")
+	ErrNoSuchSystem  = errors.New("no palettes for that system")
+)
+
+var (
+	bulbasaurStats    = []byte{1, 0x2D, 0x31, 0x31, 0x2D, 0x41}
+	mewStats          = []byte{151, 100, 100, 100, 100, 100}
+	pokedexOrderBytes = []byte{0x70, 0x73, 0x20, 0x23, 0x15, 0x64, 0x22, 0x50}
+	paletteMapBytes   = []byte{16, 22, 22, 22, 18, 18, 18, 19, 19, 19}
+)
+
+var titleVersions = map[string]string{
+	"POKEMON RED":    "red",
+	"POKEMON GREEN":  "green",
+	"POKEMON BLUE":   "blue",
+	"POKEMON YELLOW": "yellow",
+}
+
+// The number of palettes in each of the SGB and GBC palette tables.
+const numPalettes = 40
+
+// A Ripper extracts pics from a Red, Green, Blue, or Yellow ROM.
+// The whole ROM is held in memory, so a Ripper is safe for concurrent use
+// by multiple goroutines.
+type Ripper struct {
+	rom       []byte
+	lang      string
+	version   string
+	spritePos [MaxPokemon]struct {
+		front int64
+		back  int64
+	}
+	spritePalette [MaxPokemon]byte
+	sgbPalettes   []color.Palette
+	cgbPalettes   []color.Palette // nil unless the game supports the GBC
+	getBank       func(index int) int
+
+	// Offsets of the tables, for looking up glitch species.
+	dexOrderOffset   int
+	statsOffset      int
+	paletteMapOffset int
+
+	trainerTable   int64 // offset of the trainer pic and money table, or 0
+	trainerBank    int
+	trainerPalette byte
+	pics           map[string]specialPic
+	backpics       map[string]specialPic
+}
+
+// The base stats of a Pokémon, as they are stored in the ROM.
+type baseStats struct {
+	N         uint8
+	Stats     [5]uint8
+	Types     [2]uint8
+	CatchRate uint8
+	ExpYield  uint8
+
+	SpriteSize         uint8
+	FrontSpritePointer uint16
+	BackSpritePointer  uint16
+
+	Attacks    [4]uint8
+	GrowthRate uint8
+	TMs        [8]uint8
+}
+
+const (
+	baseStatsSize      = 28
+	frontPointerOffset = 11 // offsets of the pic pointers in the base stats
+	backPointerOffset  = 13
+)
+
+const bankSize = 0x4000
+
+// NewRipper reads a ROM from r and identifies it.
+func NewRipper(r io.ReaderAt) (*Ripper, error) {
+	var header [0x150]byte
+	if _, err := r.ReadAt(header[:], 0); err != nil {
+		return nil, errors.New("Couldn't recognize ROM")
+	}
+	title := strings.TrimRight(string(header[0x134:0x143]), "\x00")
+	version, ok := titleVersions[title]
+	if !ok {
+		return nil, errors.New("Couldn't recognize ROM")
+	}
+	hasCGB := header[0x143] == 0x80
+	isJP := header[0x14A] == 0
+
+	rom, err := ioutil.ReadAll(io.NewSectionReader(r, 0, maxRomSize))
+	if err != nil {
+		return nil, err
+	}
+
+	rip := new(Ripper)
+	rip.rom = rom
+	rip.version = version
+	if isJP {
+		rip.lang = "jp"
+	} else {
+		rip.lang = detectLanguage(rom)
+	}
+
+	rip.getBank = getBankRBY
+	if isJP && (version == "red" || version == "green") {
+		rip.getBank = getBankRG
+	}
+
+	// Read pokedex order
+	pos := bytes.Index(rom, pokedexOrderBytes)
+	if pos < 0 || pos+0xBE > len(rom) {
+		return nil, errors.New("Couldn't find pokedex order")
+	}
+	rip.dexOrderOffset = pos
+
+	internalId := make(map[int]int)
+	for i, n := range rom[pos : pos+0xBE] {
+		if n != 0 {
+			internalId[int(n)] = i + 1
+		}
+	}
+
+	// Read sprite pointers
+	pos = bytes.Index(rom, bulbasaurStats)
+	if pos < 0 {
+		return nil, errors.New("Couldn't find Bulbasaur's stats")
+	}
+	rip.statsOffset = pos
+
+	var stats [MaxPokemon]baseStats
+	err = binary.Read(bytes.NewReader(rom[pos:]), binary.LittleEndian, stats[:])
+	if err != nil {
+		return nil, errors.New("Couldn't read base stats")
+	}
+	for i, s := range stats {
+		bank := rip.getBank(internalId[int(s.N)])
+		base := int64(bank-1) << 14
+		rip.spritePos[i].front = base + int64(s.FrontSpritePointer)
+		rip.spritePos[i].back = base + int64(s.BackSpritePointer)
+	}
+
+	// Find Mew if missing. Mew's pics are in bank 1, so its pointers
+	// are also file offsets.
+	if stats[MaxPokemon-1].N != MaxPokemon {
+		pos = bytes.Index(rom, mewStats)
+		if pos < 0 {
+			return nil, errors.New("Couldn't find Mew's stats")
+		}
+		s := &stats[MaxPokemon-1]
+		err = binary.Read(bytes.NewReader(rom[pos:]), binary.LittleEndian, s)
+		if err != nil {
+			return nil, errors.New("Couldn't read Mew's stats")
+		}
+		rip.spritePos[MaxPokemon-1].front = int64(s.FrontSpritePointer)
+		rip.spritePos[MaxPokemon-1].back = int64(s.BackSpritePointer)
+	}
+
+	// Read palettes. The palette map is followed by the SGB palettes,
+	// and then by the GBC palettes if the game has them. The first entry
+	// in the map, for index 0, is the palette used for trainers.
+	pos = bytes.Index(rom, paletteMapBytes)
+	if pos < 0 || pos+1+MaxPokemon > len(rom) {
+		return nil, errors.New("Couldn't find palettes")
+	}
+	rip.paletteMapOffset = pos
+	rip.trainerPalette = rom[pos]
+	copy(rip.spritePalette[:], rom[pos+1:pos+1+MaxPokemon])
+
+	pr := bytes.NewReader(rom[pos+1+MaxPokemon:])
+	rip.sgbPalettes, err = readPalettes(pr)
+	if err != nil {
+		return nil, errors.New("Couldn't read SGB palettes")
+	}
+	if hasCGB {
+		rip.cgbPalettes, err = readPalettes(pr)
+		if err != nil {
+			return nil, errors.New("Couldn't read GBC palettes")
+		}
+	}
+
+	rip.findPics()
+
+	return rip, nil
+}
+
+func readPalettes(r io.Reader) ([]color.Palette, error) {
+	var palettes [numPalettes][4]RGB15
+	err := binary.Read(r, binary.LittleEndian, &palettes)
+	if err != nil {
+		return nil, err
+	}
+	var list []color.Palette
+	for _, p := range palettes {
+		var cp [4]color.Color
+		for i, c := range p {
+			cp[i] = c
+		}
+		list = append(list, cp[:])
+	}
+	return list, nil
+}
+
+// RGB15 is a 15-bit color, as used by the SGB and GBC.
+type RGB15 uint16
+
+func (rgb RGB15) RGBA() (r, g, b, a uint32) {
+	r = (uint32(rgb>>0&31)*0xFFFF + 15) / 31
+	g = (uint32(rgb>>5&31)*0xFFFF + 15) / 31
+	b = (uint32(rgb>>10&31)*0xFFFF + 15) / 31
+	a = 0xFFFF
+	return
+}
+
+// Version returns the name of the game: red, green, blue, or yellow.
+func (rip *Ripper) Version() string {
+	return rip.version
+}
+
+// Language returns the language of the game: jp, en, fr, de, it, or es.
+func (rip *Ripper) Language() string {
+	return rip.lang
+}
+
+// HasGBC reports whether the game has GBC palettes. Only Yellow does.
+func (rip *Ripper) HasGBC() bool {
+	return rip.cgbPalettes != nil
+}
+
+// Pokemon returns a Pokémon's front pic, in its PokemonPalette.
+func (rip *Ripper) Pokemon(number int) (*image.Paletted, error) {
+	if 1 > number || number > MaxPokemon {
+		return nil, ErrNoSuchPokemon
+	}
+	return rip.decodePokemon(rip.spritePos[number-1].front, number)
+}
+
+// PokemonBack returns a Pokémon's back pic, in its PokemonPalette.
+func (rip *Ripper) PokemonBack(number int) (*image.Paletted, error) {
+	if 1 > number || number > MaxPokemon {
+		return nil, ErrNoSuchPokemon
+	}
+	return rip.decodePokemon(rip.spritePos[number-1].back, number)
+}
+
+// ScaleBack doubles the size of a back pic, as the game does in battle.
+// Back pics are 4x4 tiles, but there is only room for 7x7 tiles on the
+// battle screen, so the bottom and right 4 pixels of the pic are cut off.
+func ScaleBack(m *image.Paletted) *image.Paletted {
+	w, h := m.Rect.Dx(), m.Rect.Dy()
+	if w > backSize {
+		w = backSize
+	}
+	if h > backSize {
+		h = backSize
+	}
+	scaled := image.NewPaletted(image.Rect(0, 0, w*2, h*2), m.Palette)
+	for y := 0; y < h*2; y++ {
+		for x := 0; x < w*2; x++ {
+			c := m.ColorIndexAt(m.Rect.Min.X+x/2, m.Rect.Min.Y+y/2)
+			scaled.SetColorIndex(x, y, c)
+		}
+	}
+	return scaled
+}
+
+// The number of pixels of a back pic which are shown in battle.
+const backSize = 28
+
+func (rip *Ripper) decodePokemon(off int64, number int) (*image.Paletted, error) {
+	m, err := rip.decode(off)
+	if err != nil {
+		return nil, err
+	}
+	m.Palette = rip.PokemonPalette(number)
+	return m, nil
+}
+
+func (rip *Ripper) decode(off int64) (*image.Paletted, error) {
+	if off < 0 || off >= int64(len(rip.rom)) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return Decode(bytes.NewReader(rip.rom[off:]))
+}
+
+// PokemonPalette returns the color palette for a Pokémon, or nil if there
+// is an error. It is the GBC palette if the game has one, and otherwise
+// the SGB palette.
+func (rip *Ripper) PokemonPalette(number int) color.Palette {
+	if 1 > number || number > MaxPokemon {
+		return nil
+	}
+	return rip.defaultPalette(rip.spritePalette[number-1])
+}
+
+// DefaultPalette returns a palette from the GBC table if the game has one,
+// and otherwise from the SGB table, or nil if there is an error.
+func (rip *Ripper) defaultPalette(pi byte) color.Palette {
+	system := "sgb"
+	if rip.HasGBC() {
+		system = "gbc"
+	}
+	pal, _ := rip.paletteFor(pi, system)
+	return pal
+}
+
+// PokemonPaletteFor returns the palette a Pokémon is shown in on a
+// particular system: "gb" for the original Game Boy, which only has four
+// shades of gray, "sgb" for the Super Game Boy, or "gbc" for the Game Boy
+// Color. Only Yellow has GBC palettes; the other games are colored by the
+// GBC's boot ROM instead.
+func (rip *Ripper) PokemonPaletteFor(number int, system string) (color.Palette, error) {
+	if 1 > number || number > MaxPokemon {
+		return nil, ErrNoSuchPokemon
+	}
+	return rip.paletteFor(rip.spritePalette[number-1], system)
+}
+
+func (rip *Ripper) paletteFor(pi byte, system string) (color.Palette, error) {
+	switch system {
+	case "gb":
+		return append(color.Palette(nil), grayPalette...), nil
+	case "sgb":
+		return rip.palette(rip.sgbPalettes, pi)
+	case "gbc":
+		if !rip.HasGBC() {
+			return nil, ErrNoSuchSystem
+		}
+		return rip.palette(rip.cgbPalettes, pi)
+	}
+	return nil, ErrNoSuchSystem
+}
+
+func (rip *Ripper) palette(palettes []color.Palette, i byte) (color.Palette, error) {
+	if int(i) >= len(palettes) {
+		return nil, errors.New("palette out of range")
+	}
+	return append(color.Palette(nil), palettes[i]...), nil
+}
+
+// CombinedPalette returns the Pokémon palettes for a system merged into one
+// 22-color palette: the shared white, the two middle colors of each of the
+// ten Pokémon palettes, and the shared black.
+func (rip *Ripper) CombinedPalette(system string) (p color.Palette, err error) {
+	var palettes []color.Palette
+	switch system {
+	case "sgb":
+		palettes = rip.sgbPalettes
+	case "gbc":
+		if !rip.HasGBC() {
+			return nil, ErrNoSuchSystem
+		}
+		palettes = rip.cgbPalettes
+	default:
+		return nil, ErrNoSuchSystem
+	}
+	palettes = palettes[16:26]
+	p = append(p, palettes[0][0])
+	for _, sp := range palettes {
+		p = append(p, sp[1], sp[2])
+	}
+	p = append(p, palettes[0][3])
+	return p, nil
+}
+
+// GetBankRG returns the bank containg the graphics for pokemon n.
+func getBankRG(n int) int {
+	switch {
+	case n < 0x1f:
+		return 0x9
+	case n < 0x4a:
+		return 0xa
+	case n < 0x75:
+		return 0xb
+	case n < 0x9a:
+		return 0xc
+	default:
+		return 0xd
+	}
+}
+
+func getBankRBY(n int) int {
+	switch {
+	case n < 0x1f:
+		return 0x9
+	case n < 0x4a:
+		return 0xa
+	case n < 0x74:
+		return 0xb
+	case n < 0x99:
+		return 0xc
+	default:
+		return 0xd
+	}
+}
+
+var grayPalette = color.Palette{
+	color.Gray{255},
+	color.Gray{170},
+	color.Gray{85},
+	color.Gray{0},
+}
@@ -0,0 +1,159 @@
+package rby
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"image"
+	"testing"
+)
+
+func TestDecodeBlank(t *testing.T) {
+	// A 1x1-tile pic whose bit planes are each a single run of 32 zero
+	// pairs, encoded as 1<<5 + 1 - 1:
+	//
+	//	0001 0001      width, height
+	//	0              plane order
+	//	0 11110 00001  first plane
+	//	0              mode
+	//	0 11110 00001  second plane
+	data := []byte{0x11, 0x3C, 0x13, 0xC1}
+	m, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Rect.Dx() != 8 || m.Rect.Dy() != 8 {
+		t.Fatalf("got %v, want 8x8", m.Rect)
+	}
+	for i, p := range m.Pix {
+		if p != 0 {
+			t.Fatalf("pixel %d is %d, want 0", i, p)
+		}
+	}
+}
+
+func TestDecodeShort(t *testing.T) {
+	_, err := Decode(bytes.NewReader([]byte{0x77, 0x80}))
+	if err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestNewRipperUnknown(t *testing.T) {
+	rom := make([]byte, 0x8000)
+	copy(rom[0x134:], "POKEMON_GLD")
+	if _, err := NewRipper(bytes.NewReader(rom)); err == nil {
+		t.Error("expected an error for a Gold ROM")
+	}
+}
+
+func TestScaleBack(t *testing.T) {
+	m := image.NewPaletted(image.Rect(0, 0, 32, 32), grayPalette)
+	m.SetColorIndex(0, 0, 1)
+	m.SetColorIndex(27, 27, 2)
+	m.SetColorIndex(28, 28, 3) // cut off
+	s := ScaleBack(m)
+	if s.Rect != image.Rect(0, 0, 56, 56) {
+		t.Fatalf("got %v, want 56x56", s.Rect)
+	}
+	for _, tt := range []struct {
+		x, y int
+		want uint8
+	}{
+		{0, 0, 1}, {1, 1, 1}, {2, 2, 0},
+		{54, 54, 2}, {55, 55, 2}, {53, 53, 0},
+	} {
+		if got := s.ColorIndexAt(tt.x, tt.y); got != tt.want {
+			t.Errorf("pixel (%d,%d) = %d, want %d", tt.x, tt.y, got, tt.want)
+		}
+	}
+}
+
+func TestPatternFind(t *testing.T) {
+	b := []byte{0x11, 0x22, 0x3E, 0x66, 0x22, 0x01, 0x34, 0x52, 0x3E, 0x66}
+	p := pattern{0x3E, 0x66, 0x22, 0x01, wild, wild}
+	if got := p.find(b, 0); got != 2 {
+		t.Errorf("got %d, want 2", got)
+	}
+	if got := p.find(b, 3); got != -1 {
+		t.Errorf("got %d, want -1", got)
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	for _, tt := range []struct {
+		text string
+		want string
+	}{
+		{"@NORMAL@FIGHTING@FLYING@", "en"},
+		{"@NORMAL@KAMPF@FLUG@", "de"},
+		{"@NORMAL@COMBAT@VOL@", "fr"},
+		{"@NORMALE@LOTTA@VOLANTE@", "it"},
+		{"@NORMAL@LUCHA@VOLADOR@", "es"},
+		{"@NORMAL@", "en"},
+	} {
+		rom := make([]byte, 0x8000)
+		copy(rom[0x4000:], encodeText(tt.text))
+		if got := detectLanguage(rom); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestDetectLanguageChecksum(t *testing.T) {
+	// A known checksum wins over the text.
+	rom := make([]byte, 0x8000)
+	copy(rom[0x4000:], encodeText("@NORMAL@FIGHTING@"))
+	sum := sha1.Sum(rom)
+	key := hex.EncodeToString(sum[:])
+	languageChecksums[key] = "de"
+	defer delete(languageChecksums, key)
+	if got := detectLanguage(rom); got != "de" {
+		t.Errorf("got %s, want de", got)
+	}
+	rom[0] = 1
+	if got := detectLanguage(rom); got != "en" {
+		t.Errorf("unknown checksum: got %s, want en from the text", got)
+	}
+}
+
+func TestTitlePalettes(t *testing.T) {
+	if n := len(titleChecksums) - firstSharedChecksum; n != len(fourthLetters) {
+		t.Fatalf("%d shared checksums but %d letters", n, len(fourthLetters))
+	}
+	header := func(title string, old byte) []byte {
+		h := make([]byte, headerEnd)
+		copy(h[titleOffset:], title)
+		copy(h[newLicenseeOffset:], "01")
+		h[oldLicenseeOffset] = old
+		return h
+	}
+	for _, tt := range []struct {
+		title string
+		old   byte
+		want  RGB15 // the second color of the BG palette
+	}{
+		{"POKEMON RED", 0x33, 0x421F},
+		{"POKEMON GREEN", 0x01, 0x1BEF},
+		{"POKEMON BLUE", 0x33, 0x7E8C},
+		{"POKEMON BLUE", 0x08, 0x1BEF}, // not Nintendo; default
+		{"METROID2", 0x01, 0x7E8C},     // shares a checksum with SUPER MARIOLAND
+		{"SUPER MARIOLAND", 0x01, 0x4BFF},
+	} {
+		bg := TitlePalettes(header(tt.title, tt.old)).BG
+		if bg[1] != tt.want {
+			t.Errorf("%s: got %04X, want %04X", tt.title, bg[1], tt.want)
+		}
+	}
+	up, err := ButtonPalettes("up+a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	red := TitlePalettes(header("POKEMON RED", 0x01))
+	if up.BG[1] != red.BG[1] {
+		t.Errorf("up+a: got %04X, want %04X", up.BG[1], red.BG[1])
+	}
+	if _, err := ButtonPalettes("start"); err != ErrNoSuchPalette {
+		t.Errorf("got %v, want ErrNoSuchPalette", err)
+	}
+}
@@ -3,6 +3,7 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
@@ -10,36 +11,78 @@ import (
 	"image/color"
 	"image/draw"
 	"image/gif"
+	_ "image/png"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"runtime"
 	"runtime/pprof"
 	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/magical/png"
 	"github.com/magical/sprites"
+	"github.com/magical/sprites/lcd"
+	"github.com/magical/sprites/montage"
+	"github.com/magical/sprites/rby"
 )
 
 var (
 	animFlag    bool
+	extraFlag   bool
 	framesFlag  bool
 	trainerFlag bool
 	batch       bool
+	dir         string
+	disasmFlag  bool
+	insert      string
+	namesFlag   bool
+	backFlag    bool
+	scaleFlag   bool
 	number      int
 	outname     string
 	profile     string
+	profileFile string
+	workers     int
+
+	colorCorrection string
+	correction      lcd.Profile
+
+	montageFlag bool
+	columns     int
 )
 
 func main() {
 	flag.BoolVar(&batch, "all", false, "rip all sprites")
 	flag.BoolVar(&animFlag, "anim", false, "rip animation")
+	flag.BoolVar(&extraFlag, "extra", false, "rip idle animation (crystal only)")
 	flag.BoolVar(&framesFlag, "frames", false, "rip frames")
+	flag.BoolVar(&backFlag, "back", false, "rip or insert the back pic")
+	flag.BoolVar(&scaleFlag, "scale", false, "with -back, double the size of a red/blue/yellow back pic as the game does in battle")
+	flag.StringVar(&insert, "insert", "", "insert this image as the pic for -n and write the patched ROM to -out")
 	flag.BoolVar(&trainerFlag, "trainer", false, "rip trainer")
+	flag.BoolVar(&disasmFlag, "disasm", false, "with -all, write files in the layout of the pokecrystal disassembly")
+	flag.BoolVar(&namesFlag, "names", false, "with -all, include names in file names, like 25-pikachu.png")
+	flag.StringVar(&dir, "dir", "", "render a pic from a disassembly directory instead of a ROM")
 	flag.IntVar(&number, "n", 0, "number of pokemon")
-	flag.StringVar(&outname, "out", "", "output file or directory")
-	flag.StringVar(&profile, "profile", "", "save profile data")
+	flag.StringVar(&outname, "out", "", "output file or directory; animations are written as JSON timing data if the file ends in .json")
+	flag.StringVar(&profile, "profile", "", "write a CPU profile of the ripper to this file")
+	flag.StringVar(&profileFile, "profile-file", "", "load ROM profiles (the offsets of a ROM's tables) from a JSON file; unrelated to -profile")
+	flag.IntVar(&workers, "j", runtime.NumCPU(), "number of sprites to rip in parallel")
+	flag.BoolVar(&montageFlag, "montage", false, "with -all, also draw each directory of pokemon pics as a single montage.png")
+	flag.IntVar(&columns, "columns", 15, "number of columns in a montage")
+	flag.StringVar(&colorCorrection, "color-correction", "none", "adjust colors to look as they would on a screen: "+strings.Join(lcd.Names(), ", "))
 	flag.Parse()
 
+	var err error
+	correction, err = lcd.Lookup(colorCorrection)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "-color-correction:", err)
+		return
+	}
+
 	if profile != "" {
 		f, err := os.Create(profile)
 		if err != nil {
@@ -51,8 +94,19 @@ func main() {
 		defer pprof.StopCPUProfile()
 	}
 
-	var err error
-	if batch {
+	if profileFile != "" {
+		err := loadProfiles(profileFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+	}
+
+	if insert != "" {
+		err = insertPic()
+	} else if dir != "" {
+		err = ripDir()
+	} else if batch {
 		err = ripBatch()
 	} else {
 		err = ripSingle()
@@ -62,6 +116,26 @@ func main() {
 	}
 }
 
+// LoadProfiles registers the ROM profiles in the named file.
+func loadProfiles(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	infos, err := sprites.ReadProfiles(f)
+	if err != nil {
+		return fmt.Errorf("%s: %s", filename, err)
+	}
+	for _, info := range infos {
+		err := sprites.RegisterProfile(info)
+		if err != nil {
+			return fmt.Errorf("%s: %s", filename, err)
+		}
+	}
+	return nil
+}
+
 func ripSingle() error {
 	f, err := os.Open(flag.Arg(0))
 	if err != nil {
@@ -69,6 +143,16 @@ func ripSingle() error {
 	}
 	defer f.Close()
 
+	if rip, err := rby.NewRipper(f); err == nil {
+		if backFlag && scaleFlag {
+			m, err := rip.PokemonBack(number)
+			if err != nil {
+				return err
+			}
+			return write(rby.ScaleBack(m), outname)
+		}
+		return ripSinglePokemon(rip)
+	}
 	rip, err := sprites.NewRipper(f)
 	if err != nil {
 		return err
@@ -81,32 +165,49 @@ func ripSingle() error {
 		}
 		return write(m, outname)
 	} else if animFlag && rip.HasAnimations() {
-		g, err := rip.PokemonAnimation(number)
+		a, err := rip.PokemonAnimation(number)
 		if err != nil {
 			return err
 		}
-		return write(g, outname)
+		return write(a, outname)
+	} else if extraFlag && rip.HasAnimations() {
+		a, err := rip.PokemonExtraAnimation(number)
+		if err != nil {
+			return err
+		}
+		return write(a, outname)
 	} else if framesFlag && rip.HasAnimations() {
 		frames, err := rip.PokemonFrames(number)
 		if err != nil {
 			return err
 		}
+		return write(frameStrip(frames), outname)
+	}
+	return ripSinglePokemon(rip)
+}
 
-		w, h := frames[0].Rect.Dx(), frames[0].Rect.Dy()
-		m := image.NewPaletted(image.Rect(0, 0, w*len(frames), h), frames[0].Palette)
-		for i := 0; i < len(frames); i++ {
-			r := frames[i].Rect.Add(image.Pt(w*i, 0))
-			draw.Draw(m, r, frames[i], image.ZP, draw.Src)
-		}
+// A pokemonRipper is the part of the API shared by the Gen I and Gen II
+// rippers.
+type pokemonRipper interface {
+	Version() string
+	Pokemon(number int) (*image.Paletted, error)
+	PokemonBack(number int) (*image.Paletted, error)
+	PokemonPalette(number int) color.Palette
+}
 
-		return write(m, outname)
+// RipSinglePokemon rips the front pic of a Pokémon, or the back pic with -back.
+func ripSinglePokemon(rip pokemonRipper) error {
+	var m *image.Paletted
+	var err error
+	if backFlag {
+		m, err = rip.PokemonBack(number)
 	} else {
-		m, err := rip.Pokemon(number)
-		if err != nil {
-			return err
-		}
-		return write(m, outname)
+		m, err = rip.Pokemon(number)
+	}
+	if err != nil {
+		return err
 	}
+	return write(m, outname)
 }
 
 func setPalette(v interface{}, pal color.Palette) {
@@ -122,6 +223,79 @@ func setPalette(v interface{}, pal color.Palette) {
 	}
 }
 
+// InsertPic replaces a Pokémon's front or back pic and writes out a
+// patched copy of the ROM.
+func insertPic() error {
+	if outname == "" {
+		return errors.New("-insert needs an output file")
+	}
+	f, err := os.Open(flag.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	p, err := sprites.NewPatcher(f)
+	if err != nil {
+		return err
+	}
+
+	imgfile, err := os.Open(insert)
+	if err != nil {
+		return err
+	}
+	defer imgfile.Close()
+	m, _, err := image.Decode(imgfile)
+	if err != nil {
+		return fmt.Errorf("%s: %s", insert, err)
+	}
+
+	if backFlag {
+		err = p.SetPokemonBack(number, m)
+	} else {
+		err = p.SetPokemon(number, m)
+	}
+	if err != nil {
+		return err
+	}
+	return writeFile(outname, func(w io.Writer) error {
+		_, err := p.WriteTo(w)
+		return err
+	})
+}
+
+// RipDir renders the animation of a pic in a pokecrystal-style
+// gfx/pokemon directory, for previewing edits.
+func ripDir() error {
+	p, err := sprites.LoadDisasmPic(dir, 0, 0)
+	if err != nil {
+		return err
+	}
+	if framesFlag {
+		return write(frameStrip(p.Frames), outname)
+	}
+	var a *sprites.Animation
+	if extraFlag {
+		a, err = p.ExtraAnimation()
+	} else {
+		a, err = p.Animation()
+	}
+	if err != nil {
+		return err
+	}
+	return write(a, outname)
+}
+
+// FrameStrip lays out frames side by side in a single image.
+func frameStrip(frames []*image.Paletted) *image.Paletted {
+	w, h := frames[0].Rect.Dx(), frames[0].Rect.Dy()
+	m := image.NewPaletted(image.Rect(0, 0, w*len(frames), h), frames[0].Palette)
+	for i := 0; i < len(frames); i++ {
+		r := frames[i].Rect.Add(image.Pt(w*i, 0))
+		draw.Draw(m, r, frames[i], image.ZP, draw.Src)
+	}
+	return m
+}
+
 // Write writes a *image.Paletted or *gif.GIF to the file named by outname.
 // If outname is "", it writes to os.Stdout.
 func write(v interface{}, outname string) (err error) {
@@ -133,16 +307,54 @@ func write(v interface{}, outname string) (err error) {
 		}
 		defer f.Close()
 	}
+	sBIT := outputSBIT()
 	switch v := v.(type) {
 	case *image.Paletted:
-		return png.EncodeWithSBIT(f, v, 5)
+		return png.EncodeWithSBIT(f, correctImage(v), sBIT)
 	case *gif.GIF:
-		return gif.EncodeAll(f, v)
+		return gif.EncodeAll(f, correctGIF(v))
+	case *sprites.SpriteSheet:
+		if filepath.Ext(outname) == ".json" {
+			return json.NewEncoder(f).Encode(v)
+		}
+		return png.EncodeWithSBIT(f, correctImage(v.Image), sBIT)
+	case *sprites.Animation:
+		if filepath.Ext(outname) == ".json" {
+			return json.NewEncoder(f).Encode(v)
+		}
+		return gif.EncodeAll(f, correctGIF(v.GIF()))
 	default:
 		panic("unexpected type")
 	}
 }
 
+// OutputSBIT returns the number of significant bits in each channel of the
+// colors written out. Corrected colors no longer fit in 5 bits.
+func outputSBIT() uint {
+	if colorCorrection != "none" {
+		return 8
+	}
+	return 5
+}
+
+// CorrectImage returns a copy of m with the -color-correction profile
+// applied to its palette. The pixels are shared.
+func correctImage(m *image.Paletted) *image.Paletted {
+	c := *m
+	c.Palette = correction.Palette(m.Palette)
+	return &c
+}
+
+// CorrectGIF is like correctImage, for each frame of g.
+func correctGIF(g *gif.GIF) *gif.GIF {
+	c := *g
+	c.Image = make([]*image.Paletted, len(g.Image))
+	for i, m := range g.Image {
+		c.Image[i] = correctImage(m)
+	}
+	return &c
+}
+
 func ripBatch() error {
 	for _, filename := range flag.Args() {
 		err := ripBatchFilename(filename)
@@ -159,12 +371,24 @@ func ripBatchFilename(filename string) error {
 		return err
 	}
 	defer f.Close()
+	if rip, err := rby.NewRipper(f); err == nil {
+		// The header doesn't distinguish the languages, so they're
+		// kept apart here to avoid overwriting each other.
+		dir := rip.Version()
+		if lang := rip.Language(); lang != "en" {
+			dir = lang + "-" + dir
+		}
+		return ripBatchRBY(rip, filepath.Join(outname, dir))
+	}
 	rip, err := sprites.NewRipper(f)
 	if err != nil {
 		return err
 	}
 	version := rip.Version()
 	outdir := filepath.Join(outname, version)
+	if disasmFlag {
+		return ripDisasm(rip, outdir)
+	}
 	var things = []struct {
 		fn      func(rip *sprites.Ripper, n int, form string, outname string) error
 		dirname string
@@ -175,8 +399,14 @@ func ripBatchFilename(filename string) error {
 		{ripPokemonBack, "back", ".png", true},
 		{ripShinyPokemon, "shiny", ".png", true},
 		{ripShinyPokemonBack, "back/shiny", ".png", true},
+		{ripPokemonFor("gb"), "gb", ".png", rip.HasDMG()},
+		{ripPokemonFor("sgb"), "sgb", ".png", rip.HasSGB()},
 		{ripAnimation, "animated", ".gif", rip.HasAnimations()},
 		{ripShinyAnimation, "animated/shiny", ".gif", rip.HasAnimations()},
+		{ripExtraAnimation, "animated/extra", ".gif", rip.HasAnimations()},
+		{ripIcon, "icons", ".gif", rip.HasIcons()},
+		{ripFootprint, "footprints", ".png", rip.HasFootprints()},
+		{ripShinyExtraAnimation, "animated/extra/shiny", ".gif", rip.HasAnimations()},
 	}
 	for _, t := range things {
 		if t.enabled {
@@ -186,15 +416,38 @@ func ripBatchFilename(filename string) error {
 			}
 		}
 	}
+	err = writeNames(rip, filepath.Join(outdir, "names.json"))
+	if err != nil {
+		log.Printf("names.json: %s", err)
+	}
+	// Rip everything in parallel. A Ripper is safe for concurrent use.
+	nworkers := workers
+	if nworkers < 1 {
+		nworkers = 1
+	}
+	jobs := make(chan func())
+	var wg sync.WaitGroup
+	for i := 0; i < nworkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				job()
+			}
+		}()
+	}
 	for n := 1; n <= sprites.MaxPokemon; n++ {
 		for _, t := range things {
 			if !t.enabled {
 				continue
 			}
-			name := filepath.Join(filepath.FromSlash(t.dirname), strconv.Itoa(n))
-			err := t.fn(rip, n, "", filepath.Join(outdir, name+t.ext))
-			if err != nil {
-				log.Printf("%s: %s", name, err)
+			n, t := n, t
+			jobs <- func() {
+				name := filepath.Join(filepath.FromSlash(t.dirname), pokemonFileName(rip, n, ""))
+				err := t.fn(rip, n, "", filepath.Join(outdir, name+t.ext))
+				if err != nil {
+					log.Printf("%s: %s", name, err)
+				}
 			}
 		}
 	}
@@ -203,22 +456,156 @@ func ripBatchFilename(filename string) error {
 			if !t.enabled {
 				continue
 			}
-			name := filepath.Join(filepath.FromSlash(t.dirname), "201-"+form)
-			err := t.fn(rip, 201, form, filepath.Join(outdir, name+t.ext))
-			if err != nil {
-				log.Printf("%s: %s", name, err)
+			form, t := form, t
+			jobs <- func() {
+				name := filepath.Join(filepath.FromSlash(t.dirname), pokemonFileName(rip, 201, form))
+				err := t.fn(rip, 201, form, filepath.Join(outdir, name+t.ext))
+				if err != nil {
+					log.Printf("%s: %s", name, err)
+				}
 			}
 		}
 	}
 	os.MkdirAll(filepath.Join(outdir, "trainers"), 0777)
 	for n := 1; n <= sprites.MaxTrainer; n++ {
-		name := filepath.Join("trainers", strconv.Itoa(n))
-		m, err := rip.Trainer(n)
+		n := n
+		jobs <- func() {
+			name := filepath.Join("trainers", trainerFileName(rip, n))
+			m, err := rip.Trainer(n)
+			if err != nil {
+				log.Printf("%s: %s", name, err)
+				return
+			}
+			err = write(m, filepath.Join(outdir, name+".png"))
+			if err != nil {
+				log.Printf("%s: %s", name, err)
+			}
+		}
+	}
+	if names := rip.BackpicNames(); len(names) > 0 {
+		os.MkdirAll(filepath.Join(outdir, "trainers", "back"), 0777)
+		for _, name := range names {
+			name := name
+			jobs <- func() {
+				m, err := rip.Backpic(name)
+				if err == nil {
+					err = write(m, filepath.Join(outdir, "trainers", "back", name+".png"))
+				}
+				if err != nil {
+					log.Printf("%s: %s", name, err)
+				}
+			}
+		}
+	}
+	if rip.HasOverworld() {
+		os.MkdirAll(filepath.Join(outdir, "overworld"), 0777)
+		for n := 1; n <= rip.NumOverworld(); n++ {
+			n := n
+			jobs <- func() {
+				name := filepath.Join("overworld", strconv.Itoa(n))
+				s, err := rip.Overworld(n)
+				if err == nil {
+					err = write(s, filepath.Join(outdir, name+".png"))
+				}
+				if err == nil {
+					err = write(s, filepath.Join(outdir, name+".json"))
+				}
+				if err != nil {
+					log.Printf("%s: %s", name, err)
+				}
+			}
+		}
+	}
+	jobs <- func() {
+		m, err := rip.Egg()
+		if err == nil {
+			err = write(m, filepath.Join(outdir, "egg.png"))
+		}
 		if err != nil {
-			log.Printf("%s: %s", name, err)
+			log.Printf("egg: %s", err)
+		}
+	}
+	if montageFlag {
+		for _, t := range []picDir{{rip.Pokemon, ""}, {rip.PokemonBack, "back"}} {
+			t := t
+			jobs <- func() {
+				name := filepath.Join(filepath.FromSlash(t.dirname), "montage.png")
+				err := writeMontage(t.fn, sprites.MaxPokemon, filepath.Join(outdir, name))
+				if err != nil {
+					log.Printf("%s: %s", name, err)
+				}
+			}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return nil
+}
+
+// RipBatchRBY rips everything from a Red, Green, Blue, or Yellow ROM, in
+// the same layout as for Gold, Silver, and Crystal.
+func ripBatchRBY(rip *rby.Ripper, outdir string) error {
+	scaledBack := func(n int) (*image.Paletted, error) {
+		m, err := rip.PokemonBack(n)
+		if err != nil {
+			return nil, err
+		}
+		return rby.ScaleBack(m), nil
+	}
+	err := ripBatchPokemon(rip, rby.MaxPokemon, outdir, picDir{scaledBack, "back/scaled"})
+	if err != nil {
+		return err
+	}
+
+	type namedPic struct {
+		name string
+		fn   func() (*image.Paletted, error)
+	}
+	var pics []namedPic
+	if rip.HasTrainers() {
+		for n := 1; n <= rby.MaxTrainer; n++ {
+			n := n
+			pics = append(pics, namedPic{filepath.Join("trainers", strconv.Itoa(n)), func() (*image.Paletted, error) { return rip.Trainer(n) }})
+		}
+	}
+	for _, name := range rip.BackpicNames() {
+		name := name
+		pics = append(pics, namedPic{filepath.Join("trainers", "back", name), func() (*image.Paletted, error) { return rip.Backpic(name) }})
+	}
+	// The player's front pic goes with the trainers; the rest go at the top.
+	for _, name := range rip.PicNames() {
+		name := name
+		dir := ""
+		if name == "player" {
+			dir = "trainers"
+		}
+		pics = append(pics, namedPic{filepath.Join(dir, name), func() (*image.Paletted, error) { return rip.Pic(name) }})
+	}
+	for _, p := range pics {
+		os.MkdirAll(filepath.Join(outdir, filepath.Dir(p.name)), 0777)
+		m, err := p.fn()
+		if err == nil {
+			err = write(m, filepath.Join(outdir, p.name+".png"))
+		}
+		if err != nil {
+			log.Printf("%s: %s", p.name, err)
+		}
+	}
+
+	// Glitch species, by internal index. Their pics are often garbage; any
+	// that can be decoded are written even if they are too large.
+	os.MkdirAll(filepath.Join(outdir, "glitch"), 0777)
+	for i := 1; i <= rby.MaxIndex; i++ {
+		if rip.PokedexNumber(i) != 0 {
 			continue
 		}
-		err = write(m, filepath.Join(outdir, name+".png"))
+		name := filepath.Join("glitch", strconv.Itoa(i))
+		m, err := rip.PokemonByIndex(i)
+		if m != nil {
+			if werr := write(m, filepath.Join(outdir, name+".png")); werr != nil {
+				err = werr
+			}
+		}
 		if err != nil {
 			log.Printf("%s: %s", name, err)
 		}
@@ -226,18 +613,190 @@ func ripBatchFilename(filename string) error {
 	return nil
 }
 
+// A picDir is a kind of pic to rip in batch mode and the directory to put it in.
+type picDir struct {
+	fn      func(number int) (*image.Paletted, error)
+	dirname string
+}
+
+// RipBatchPokemon rips the front and back pics of Pokémon 1 to max, plus any
+// extra kinds of pics, for games which don't have anything else to rip.
+func ripBatchPokemon(rip pokemonRipper, max int, outdir string, extra ...picDir) error {
+	dirs := []picDir{
+		{rip.Pokemon, ""},
+		{rip.PokemonBack, "back"},
+	}
+	for _, t := range append(dirs, extra...) {
+		err := os.MkdirAll(filepath.Join(outdir, filepath.FromSlash(t.dirname)), 0777)
+		if err != nil && !os.IsExist(err) {
+			return err
+		}
+		for n := 1; n <= max; n++ {
+			name := filepath.Join(filepath.FromSlash(t.dirname), strconv.Itoa(n))
+			m, err := t.fn(n)
+			if err == nil {
+				err = write(m, filepath.Join(outdir, name+".png"))
+			}
+			if err != nil {
+				log.Printf("%s: %s", name, err)
+			}
+		}
+		if montageFlag {
+			name := filepath.Join(filepath.FromSlash(t.dirname), "montage.png")
+			err := writeMontage(t.fn, max, filepath.Join(outdir, name))
+			if err != nil {
+				log.Printf("%s: %s", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// WriteMontage draws the pics of Pokémon 1 through max in a grid of
+// -columns columns and writes it to filename. Pics which can't be ripped
+// are left out; their errors are logged by the caller.
+func writeMontage(fn func(number int) (*image.Paletted, error), max int, filename string) error {
+	pics := make([]image.Image, max)
+	for n := 1; n <= max; n++ {
+		if m, err := fn(n); err == nil {
+			pics[n-1] = correctImage(m)
+		}
+	}
+	m := montage.Draw(pics, &montage.Options{Columns: columns})
+	return writeFile(filename, func(w io.Writer) error {
+		return png.EncodeWithSBIT(w, m, outputSBIT())
+	})
+}
+
+// PokemonFileName returns the base file name for a Pokémon, or an Unown
+// form if form isn't empty: 25 or 25-pikachu, and 201-a or 201-unown-a.
+func pokemonFileName(rip *sprites.Ripper, number int, form string) string {
+	name := strconv.Itoa(number)
+	if namesFlag {
+		if s, err := rip.PokemonName(number); err == nil {
+			name += "-" + slug(s)
+		}
+	}
+	if form != "" {
+		name += "-" + form
+	}
+	return name
+}
+
+func trainerFileName(rip *sprites.Ripper, number int) string {
+	name := strconv.Itoa(number)
+	if namesFlag {
+		if s, err := rip.TrainerClassName(number); err == nil {
+			name += "-" + slug(s)
+		}
+	}
+	return name
+}
+
+// Slug converts a name from the ROM into something suitable for a file name.
+func slug(s string) string {
+	s = strings.NewReplacer("♀", " f", "♂", " m", "é", "e", "'", "", ".", " ").Replace(strings.ToLower(s))
+	return strings.Join(strings.Fields(s), "-")
+}
+
+// WriteNames writes the Pokémon and trainer class names as JSON objects
+// keyed by number.
+func writeNames(rip *sprites.Ripper, filename string) error {
+	var names struct {
+		Pokemon  map[int]string `json:"pokemon"`
+		Trainers map[int]string `json:"trainers"`
+	}
+	names.Pokemon = make(map[int]string)
+	names.Trainers = make(map[int]string)
+	for n := 1; n <= sprites.MaxPokemon; n++ {
+		s, err := rip.PokemonName(n)
+		if err != nil {
+			return err
+		}
+		names.Pokemon[n] = s
+	}
+	for n := 1; n <= sprites.MaxTrainer; n++ {
+		s, err := rip.TrainerClassName(n)
+		if err != nil {
+			return err
+		}
+		names.Trainers[n] = s
+	}
+	return writeFile(filename, func(w io.Writer) error {
+		e := json.NewEncoder(w)
+		e.SetIndent("", "\t")
+		return e.Encode(names)
+	})
+}
+
+// Icons and footprints are the same for every Unown form, so they are
+// only ripped once, without a form.
+
+func ripIcon(rip *sprites.Ripper, number int, form string, outname string) error {
+	if form != "" {
+		return nil
+	}
+	frames, err := rip.Icon(number)
+	if err != nil {
+		return err
+	}
+	g := &gif.GIF{}
+	for _, m := range frames {
+		g.Image = append(g.Image, m)
+		g.Delay = append(g.Delay, iconDelay)
+	}
+	return write(g, outname)
+}
+
+// IconDelay is the time each frame of an icon is shown, in centiseconds.
+const iconDelay = 15
+
+func ripFootprint(rip *sprites.Ripper, number int, form string, outname string) error {
+	if form != "" {
+		return nil
+	}
+	m, err := rip.Footprint(number)
+	if err != nil {
+		return err
+	}
+	return write(m, outname)
+}
+
 func ripAnimation(rip *sprites.Ripper, number int, form string, outname string) error {
-	var g *gif.GIF
+	var a *sprites.Animation
 	var err error
 	if number == 201 && form != "" {
-		g, err = rip.UnownAnimation(form)
+		a, err = rip.UnownAnimation(form)
 	} else {
-		g, err = rip.PokemonAnimation(number)
+		a, err = rip.PokemonAnimation(number)
 	}
 	if err != nil {
 		return err
 	}
-	return write(g, outname)
+	return writeAnimation(a, outname)
+}
+
+func ripExtraAnimation(rip *sprites.Ripper, number int, form string, outname string) error {
+	var a *sprites.Animation
+	var err error
+	if number == 201 && form != "" {
+		a, err = rip.UnownExtraAnimation(form)
+	} else {
+		a, err = rip.PokemonExtraAnimation(number)
+	}
+	if err != nil {
+		return err
+	}
+	return writeAnimation(a, outname)
+}
+
+// WriteAnimation writes an animation to outname as a GIF, and its timing
+// to a .json file of the same name.
+func writeAnimation(a *sprites.Animation, outname string) error {
+	if err := write(a, outname); err != nil {
+		return err
+	}
+	return write(a, strings.TrimSuffix(outname, filepath.Ext(outname))+".json")
 }
 
 func ripPokemon(rip *sprites.Ripper, number int, form string, outname string) error {
@@ -268,6 +827,28 @@ func ripPokemonBack(rip *sprites.Ripper, number int, form string, outname string
 	return write(m, outname)
 }
 
+// RipPokemonFor returns a function which rips a front pic with its palette
+// on the given system.
+func ripPokemonFor(system string) func(*sprites.Ripper, int, string, string) error {
+	return func(rip *sprites.Ripper, number int, form string, outname string) error {
+		var m *image.Paletted
+		var err error
+		if number == 201 && form != "" {
+			m, err = rip.Unown(form)
+		} else {
+			m, err = rip.Pokemon(number)
+		}
+		if err != nil {
+			return err
+		}
+		m.Palette, err = rip.PokemonPaletteFor(number, system)
+		if err != nil {
+			return err
+		}
+		return write(m, outname)
+	}
+}
+
 // TODO: It would be nice to just do a palette
 //       swap instead of re-ripping the images.
 
@@ -308,12 +889,12 @@ func ripShinyPokemonBack(rip *sprites.Ripper, number int, form string, outname s
 }
 
 func ripShinyAnimation(rip *sprites.Ripper, number int, form string, outname string) error {
-	var g *gif.GIF
+	var a *sprites.Animation
 	var err error
 	if number == 201 && form != "" {
-		g, err = rip.UnownAnimation(form)
+		a, err = rip.UnownAnimation(form)
 	} else {
-		g, err = rip.PokemonAnimation(number)
+		a, err = rip.PokemonAnimation(number)
 	}
 	if err != nil {
 		return err
@@ -322,8 +903,149 @@ func ripShinyAnimation(rip *sprites.Ripper, number int, form string, outname str
 	if pal == nil {
 		return errors.New("couldn't get palette")
 	}
-	for _, m := range g.Image {
+	for _, m := range a.Frames {
 		m.Palette = pal
 	}
-	return write(g, outname)
+	return write(a, outname)
+}
+
+func ripShinyExtraAnimation(rip *sprites.Ripper, number int, form string, outname string) error {
+	var a *sprites.Animation
+	var err error
+	if number == 201 && form != "" {
+		a, err = rip.UnownExtraAnimation(form)
+	} else {
+		a, err = rip.PokemonExtraAnimation(number)
+	}
+	if err != nil {
+		return err
+	}
+	pal := rip.ShinyPalette(number)
+	if pal == nil {
+		return errors.New("couldn't get palette")
+	}
+	for _, m := range a.Frames {
+		m.Palette = pal
+	}
+	return write(a, outname)
+}
+
+// RipDisasm writes the pics, palettes, and animations in the layout of the
+// pokecrystal and pokegold disassemblies, under gfx/pokemon.
+func ripDisasm(rip *sprites.Ripper, outdir string) error {
+	gfxdir := filepath.Join(outdir, "gfx", "pokemon")
+	for n := 1; n <= sprites.MaxPokemon; n++ {
+		name := sprites.DisasmName(n, "")
+		err := writeDisasmPalettes(rip, n, filepath.Join(gfxdir, name))
+		if err != nil {
+			log.Printf("%s: %s", name, err)
+		}
+		if n == 201 {
+			for _, form := range sprites.UnownForms {
+				name := sprites.DisasmName(n, form)
+				err := writeDisasmPic(rip, n, form, filepath.Join(gfxdir, name))
+				if err != nil {
+					log.Printf("%s: %s", name, err)
+				}
+			}
+			continue
+		}
+		err = writeDisasmPic(rip, n, "", filepath.Join(gfxdir, name))
+		if err != nil {
+			log.Printf("%s: %s", name, err)
+		}
+	}
+	return nil
+}
+
+func writeDisasmPic(rip *sprites.Ripper, number int, form string, dir string) error {
+	var front, back *sprites.RawPic
+	var err error
+	if form != "" {
+		front, err = rip.RawUnown(form)
+		if err == nil {
+			back, err = rip.RawUnownBack(form)
+		}
+	} else {
+		front, err = rip.RawPokemon(number)
+		if err == nil {
+			back, err = rip.RawPokemonBack(number)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	files := []outFile{
+		{"front.animated.2bpp.lz", writeBytes(front.Compressed)},
+		{"front.animated.2bpp", writeBytes(front.Tiles)},
+		{"front.dimensions", writeBytes([]byte{byte(front.Width<<4 | front.Height)})},
+		{"back.2bpp", writeBytes(back.Tiles)},
+	}
+	if rip.HasAnimations() {
+		var d *sprites.AnimData
+		if form != "" {
+			d, err = rip.UnownAnimData(form)
+		} else {
+			d, err = rip.PokemonAnimData(number)
+		}
+		if err != nil {
+			return err
+		}
+		files = append(files, []outFile{
+			{"anim.asm", func(w io.Writer) error { return sprites.WriteAnimScript(w, d.Anim) }},
+			{"anim_idle.asm", func(w io.Writer) error { return sprites.WriteAnimScript(w, d.Extra) }},
+			{"bitmask.asm", func(w io.Writer) error { return sprites.WriteBitmasks(w, d.Bitmaps) }},
+			{"frames.asm", func(w io.Writer) error { return sprites.WriteFrames(w, d.Frames) }},
+		}...)
+	}
+	for _, f := range files {
+		err := writeFile(filepath.Join(dir, f.name), f.fn)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// An outFile is a file to be written by writeFile.
+type outFile struct {
+	name string
+	fn   func(w io.Writer) error
+}
+
+func writeDisasmPalettes(rip *sprites.Ripper, number int, dir string) error {
+	normal, shiny := rip.PokemonPalette(number), rip.ShinyPalette(number)
+	if normal == nil || shiny == nil {
+		return errors.New("couldn't get palette")
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	err := writeFile(filepath.Join(dir, "normal.pal"), func(w io.Writer) error { return sprites.WritePalette(w, normal) })
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(dir, "shiny.pal"), func(w io.Writer) error { return sprites.WritePalette(w, shiny) })
+}
+
+func writeBytes(b []byte) func(w io.Writer) error {
+	return func(w io.Writer) error {
+		_, err := w.Write(b)
+		return err
+	}
+}
+
+func writeFile(filename string, fn func(w io.Writer) error) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	err = fn(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
 }
@@ -0,0 +1,143 @@
+package sprites
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+)
+
+// The English character map used by Gold, Silver, and Crystal.
+// Characters which don't appear in names are left out.
+var charmap = func() (m [256]string) {
+	for i := 0; i < 26; i++ {
+		m[0x80+i] = string('A' + rune(i))
+		m[0xA0+i] = string('a' + rune(i))
+	}
+	for i := 0; i < 10; i++ {
+		m[0xF6+i] = string('0' + rune(i))
+	}
+	for b, s := range map[byte]string{
+		0x54: "POKé",
+		0x7F: " ",
+		0x9A: "(", 0x9B: ")", 0x9C: ":", 0x9D: ";", 0x9E: "[", 0x9F: "]",
+		0xC0: "Ä", 0xC1: "Ö", 0xC2: "Ü", 0xC3: "ä", 0xC4: "ö", 0xC5: "ü",
+		0xD0: "'d", 0xD1: "'l", 0xD2: "'m", 0xD3: "'r", 0xD4: "'s", 0xD5: "'t", 0xD6: "'v",
+		0xE0: "'", 0xE1: "PK", 0xE2: "MN", 0xE3: "-",
+		0xE6: "?", 0xE7: "!", 0xE8: ".", 0xE9: "&", 0xEA: "é",
+		0xEF: "♂", 0xF0: "¥", 0xF1: "×", 0xF2: ".", 0xF3: "/", 0xF4: ",", 0xF5: "♀",
+	} {
+		m[b] = s
+	}
+	return m
+}()
+
+// The string terminator.
+const textEnd = 0x50
+
+// DecodeText converts a string from the ROM, stopping at the terminator.
+// Unknown characters become U+FFFD.
+func decodeText(b []byte) string {
+	var s strings.Builder
+	for _, c := range b {
+		if c == textEnd {
+			break
+		}
+		if charmap[c] == "" {
+			s.WriteRune('�')
+		} else {
+			s.WriteString(charmap[c])
+		}
+	}
+	return s.String()
+}
+
+// EncodeText is the inverse of decodeText, for strings of capital letters
+// and spaces. It is used for finding text.
+func encodeText(s string) []byte {
+	var b []byte
+	for _, r := range s {
+		switch {
+		case 'A' <= r && r <= 'Z':
+			b = append(b, byte(0x80+r-'A'))
+		case r == '@':
+			b = append(b, textEnd)
+		default:
+			b = append(b, 0x7F)
+		}
+	}
+	return b
+}
+
+const pokemonNameSize = 10
+
+// FindPokemonNames finds the species name table, which is a list of
+// fixed-size names padded with the terminator.
+func findPokemonNames(rom []byte) int64 {
+	bulbasaur := encodeText("BULBASAUR@")
+	ivysaur := encodeText("IVYSAUR@")
+	for i := 0; ; {
+		pos := bytes.Index(rom[i:], bulbasaur)
+		if pos < 0 {
+			return 0
+		}
+		pos += i
+		i = pos + 1
+		if pos+pokemonNameSize*MaxPokemon <= len(rom) && bytes.HasPrefix(rom[pos+pokemonNameSize:], ivysaur) {
+			return int64(pos)
+		}
+	}
+}
+
+// FindTrainerClassNames finds the trainer class name table, which starts
+// with the names of the first two gym leaders' classes.
+func findTrainerClassNames(rom []byte) int64 {
+	pos := bytes.Index(rom, encodeText("LEADER@LEADER@"))
+	if pos < 0 {
+		return 0
+	}
+	return int64(pos)
+}
+
+var errNoNames = errors.New("couldn't find names in ROM")
+
+// PokemonName returns the name of a Pokémon species.
+func (rip *Ripper) PokemonName(number int) (string, error) {
+	if 1 > number || number > MaxPokemon {
+		return "", ErrNoSuchPokemon
+	}
+	if rip.info.PokemonNamesOffset == 0 {
+		return "", errNoNames
+	}
+	b := make([]byte, pokemonNameSize)
+	off := rip.info.PokemonNamesOffset + int64(number-1)*pokemonNameSize
+	_, err := rip.r.ReadAt(b, off)
+	if err != nil {
+		return "", &TableError{"pokemon name", number - 1, off, noEOF(err)}
+	}
+	return decodeText(b), nil
+}
+
+// TrainerClassName returns the name of a trainer class. The names are
+// terminated strings, one after the other, starting with class 1.
+func (rip *Ripper) TrainerClassName(number int) (string, error) {
+	if 1 > number || number > MaxTrainer {
+		return "", ErrNoSuchTrainer
+	}
+	if rip.info.TrainerClassNamesOffset == 0 {
+		return "", errNoNames
+	}
+	off := rip.info.TrainerClassNamesOffset
+	for i := 1; ; i++ {
+		if off < 0 || off >= int64(len(rip.rom)) {
+			return "", &TableError{"trainer class name", number - 1, off, ErrMalformed}
+		}
+		n := bytes.IndexByte(rip.rom[off:], textEnd)
+		if n < 0 {
+			return "", &TableError{"trainer class name", number - 1, off, ErrMalformed}
+		}
+		if i == number {
+			return decodeText(rip.rom[off : off+int64(n)]), nil
+		}
+		off += int64(n) + 1
+	}
+}
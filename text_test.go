@@ -0,0 +1,42 @@
+package sprites
+
+import "testing"
+
+func TestDecodeText(t *testing.T) {
+	for _, tt := range []struct {
+		b    []byte
+		want string
+	}{
+		{[]byte{0x8C, 0x91, 0xE8, 0x8C, 0x88, 0x8C, 0x84, 0x50, 0x50, 0x50}, "MR.MIME"},
+		{[]byte{0x8D, 0x88, 0x83, 0x8E, 0x91, 0x80, 0x8D, 0xF5, 0x50}, "NIDORAN♀"},
+		{[]byte{0xE1, 0xE2, 0x7F, 0x93, 0x91, 0x80, 0x88, 0x8D, 0x84, 0x91}, "PKMN TRAINER"},
+		{[]byte{0x85, 0x00, 0x50}, "F�"},
+	} {
+		if got := decodeText(tt.b); got != tt.want {
+			t.Errorf("decodeText(% x) = %q, want %q", tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestNames(t *testing.T) {
+	rom := make([]byte, 0x4000)
+	for i := range rom {
+		rom[i] = 0xFF
+	}
+	copy(rom[0x1000:], encodeText("BULBASAUR@"))
+	copy(rom[0x100A:], encodeText("IVYSAUR@@@"))
+	copy(rom[0x3000:], encodeText("LEADER@LEADER@LEADER@RIVAL@"))
+	if off := findPokemonNames(rom); off != 0x1000 {
+		t.Errorf("findPokemonNames: got %#x, want %#x", off, 0x1000)
+	}
+	if off := findTrainerClassNames(rom); off != 0x3000 {
+		t.Errorf("findTrainerClassNames: got %#x, want %#x", off, 0x3000)
+	}
+	rip := newTestRipper(rom, RomInfo{PokemonNamesOffset: 0x1000, TrainerClassNamesOffset: 0x3000})
+	if name, err := rip.PokemonName(2); name != "IVYSAUR" || err != nil {
+		t.Errorf("PokemonName(2) = %q, %v", name, err)
+	}
+	if name, err := rip.TrainerClassName(4); name != "RIVAL" || err != nil {
+		t.Errorf("TrainerClassName(4) = %q, %v", name, err)
+	}
+}